@@ -33,7 +33,7 @@ func ExecuteDecrypt() error {
 		}
 	}
 
-	res, err := client.NewClient(client.Config{
+	res, err := newClient(client.Config{
 		URI: dp.source,
 	}).Decrypt(dp.value)
 
@@ -1,8 +1,8 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"net/http"
 	"os"
 	"strings"
 
@@ -14,14 +14,19 @@ import (
 
 var diffp = struct {
 	source        string
-	application   string
-	profile       string
+	application   []string
+	profile       []string
 	label         string
 	format        string
 	destination   string
 	files         string
-	targetProfile string
+	targetProfile []string
 	targetLabel   string
+	ignore        IgnoreRules
+	ignoreFile    string
+	color         string
+	sideBySide    bool
+	width         int
 }{}
 
 var diffCmd = &cobra.Command{
@@ -52,10 +57,37 @@ func validateDiffParams(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if diffp.targetProfile == "" {
+	if len(diffp.targetProfile) == 0 {
 		diffp.targetProfile = diffp.profile
 	}
-	return nil
+
+	for _, c := range []client.Config{
+		diffClientConfig(diffp.profile, diffp.label),
+		diffClientConfig(diffp.targetProfile, diffp.targetLabel),
+	} {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return loadIgnoreFile(diffp.ignoreFile, cmd.Flags().Changed("ignore-file"))
+}
+
+func diffClientConfig(profile []string, label string) client.Config {
+	return client.Config{URI: diffp.source, Profiles: profile, Applications: diffp.application, Label: label}
+}
+
+func loadIgnoreFile(filename string, required bool) error {
+	if filename == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(filename); os.IsNotExist(err) && !required {
+		return nil
+	}
+
+	log.Debug("Loading ignore rules from: ", filename)
+	return diffp.ignore.Load(filename)
 }
 
 // ExecuteDiffValues runs diff values cmd.
@@ -65,93 +97,105 @@ func ExecuteDiffValues() error {
 		return err
 	}
 
-	respA, err := client.
-		NewClient(client.Config{URI: diffp.source, Profile: diffp.profile, Application: diffp.application, Label: diffp.label}).
+	respA, err := newClient(diffClientConfig(diffp.profile, diffp.label)).
 		FetchAs(ext)
 	if err != nil {
 		return err
 	}
 
-	log.Debugf("Config server response for label %s, profile %s:", diffp.label, diffp.profile)
+	log.Debugf("Config server response for label %s, profile %s:", diffp.label, strings.Join(diffp.profile, ","))
 	log.Debug(string(respA))
 
-	respB, err := client.
-		NewClient(client.Config{URI: diffp.source, Profile: diffp.targetProfile, Application: diffp.application, Label: diffp.targetLabel}).
+	respB, err := newClient(diffClientConfig(diffp.targetProfile, diffp.targetLabel)).
 		FetchAs(ext)
 	if err != nil {
 		return err
 	}
 
-	log.Debugf("Config server response for label %s, profile %s:", diffp.targetLabel, diffp.targetProfile)
+	log.Debugf("Config server response for label %s, profile %s:", diffp.targetLabel, strings.Join(diffp.targetProfile, ","))
 	log.Debug(string(respB))
 
-	d := difflib.UnifiedDiff{
-		A:       difflib.SplitLines(string(respA)),
-		B:       difflib.SplitLines(string(respB)),
-		Context: 3,
+	filteredA, err := diffp.ignore.Filter([]byte(respA), diffp.format)
+	if err != nil {
+		return fmt.Errorf("unable to apply ignore rules for label %s and profile %s: %v", diffp.label, strings.Join(diffp.profile, ","), err)
+	}
+
+	filteredB, err := diffp.ignore.Filter([]byte(respB), diffp.format)
+	if err != nil {
+		return fmt.Errorf("unable to apply ignore rules for label %s and profile %s: %v", diffp.targetLabel, strings.Join(diffp.targetProfile, ","), err)
+	}
+
+	renderer, err := newDiffRenderer(diffp.color, diffp.sideBySide, diffp.width)
+	if err != nil {
+		return err
 	}
 
-	return difflib.WriteUnifiedDiff(os.Stdout, d)
+	return renderer.Render(difflib.SplitLines(string(filteredA)), difflib.SplitLines(string(filteredB)))
+}
+
+// fetchDiffFile fetches the file, the file missing for the profile is diffed as empty.
+func fetchDiffFile(profile []string, label string, filename string) ([]byte, error) {
+	data, err := newClient(diffClientConfig(profile, label)).FetchFileE(filename)
+	if errors.Is(err, client.ErrNotFound) && !errors.Is(err, client.ErrInvalidLabel) {
+		return []byte{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("file %s for label %s and profile %s cannot be retrieved from remote server %s: %w",
+			filename, label, strings.Join(profile, ","), diffp.source, err)
+	}
+	return data, nil
 }
 
 // ExecuteDiffFiles runs diff files cmd.
 func ExecuteDiffFiles() error {
-	errorHandler := func(data []byte, err error) []byte {
-		if e, ok := err.(client.HTTPError); ok && e.StatusCode() == http.StatusNotFound {
-			return []byte{}
-		}
-		fmt.Println(err.Error())
-		return nil
+	renderer, err := newDiffRenderer(diffp.color, diffp.sideBySide, diffp.width)
+	if err != nil {
+		return err
 	}
 
 	for _, filename := range strings.Split(diffp.files, ",") {
-		respA := client.
-			NewClient(client.Config{URI: diffp.source, Profile: diffp.profile, Application: diffp.application, Label: diffp.label}).
-			FetchFile(filename, errorHandler)
-
-		if respA == nil {
-			return fmt.Errorf("file %s for label %s and profile %s cannot be retrieved from remote server %s",
-				filename, diffp.label, diffp.profile, diffp.source)
+		respA, err := fetchDiffFile(diffp.profile, diffp.label, filename)
+		if err != nil {
+			return err
 		}
 
-		log.Debugf("Config server response for label %s, profile %s:", diffp.label, diffp.profile)
+		log.Debugf("Config server response for label %s, profile %s:", diffp.label, strings.Join(diffp.profile, ","))
 		log.Debug(string(respA))
 
-		respB := client.
-			NewClient(client.Config{URI: diffp.source, Profile: diffp.targetProfile, Application: diffp.application, Label: diffp.targetLabel}).
-			FetchFile(filename, errorHandler)
-
-		if respB == nil {
-			return fmt.Errorf("file %s for label %s and profile %s cannot be retrieved from remote server %s",
-				filename, diffp.targetLabel, diffp.targetProfile, diffp.source)
+		respB, err := fetchDiffFile(diffp.targetProfile, diffp.targetLabel, filename)
+		if err != nil {
+			return err
 		}
 
-		log.Debugf("Config server response for label %s, profile %s:", diffp.targetLabel, diffp.targetProfile)
+		log.Debugf("Config server response for label %s, profile %s:", diffp.targetLabel, strings.Join(diffp.targetProfile, ","))
 		log.Debug(string(respB))
 
-		d := difflib.UnifiedDiff{
-			A:       difflib.SplitLines(string(respA)),
-			B:       difflib.SplitLines(string(respB)),
-			Context: 3,
+		respA, err = diffp.ignore.FilterFile(respA, filename)
+		if err != nil {
+			return fmt.Errorf("unable to apply ignore rules to file %s for label %s and profile %s: %v",
+				filename, diffp.label, strings.Join(diffp.profile, ","), err)
 		}
 
-		diffString, err := difflib.GetUnifiedDiffString(d)
+		respB, err = diffp.ignore.FilterFile(respB, filename)
 		if err != nil {
-			return err
+			return fmt.Errorf("unable to apply ignore rules to file %s for label %s and profile %s: %v",
+				filename, diffp.targetLabel, strings.Join(diffp.targetProfile, ","), err)
 		}
 
-		printFileDiff(diffString, filename)
+		err = renderer.Render(difflib.SplitLines(string(respA)), difflib.SplitLines(string(respB)), fileDiffHeader(filename)...)
+		if err != nil {
+			return err
+		}
 	}
 	log.Debug("Diff of files written to stdout")
 	return nil
 }
 
-func printFileDiff(diffString string, filename string) {
-	if len(diffString) > 0 {
-		fmt.Printf("diff a/%s b/%s\n", filename, filename)
-		fmt.Printf("--- a/%s profile=%s label=%s\n", filename, diffp.profile, diffp.label)
-		fmt.Printf("+++ b/%s profile=%s label=%s\n", filename, diffp.targetProfile, diffp.targetLabel)
-		fmt.Print(diffString)
+func fileDiffHeader(filename string) []string {
+	return []string{
+		fmt.Sprintf("diff a/%s b/%s", filename, filename),
+		fmt.Sprintf("--- a/%s profile=%s label=%s", filename, strings.Join(diffp.profile, ","), diffp.label),
+		fmt.Sprintf("+++ b/%s profile=%s label=%s", filename, strings.Join(diffp.targetProfile, ","), diffp.targetLabel),
 	}
 }
 
@@ -159,11 +203,16 @@ func init() {
 	diffCmd.AddCommand(diffFilesCmd)
 	diffCmd.AddCommand(diffValuesCmd)
 	diffCmd.PersistentFlags().StringVarP(&diffp.source, "source", "s", "", "address of the config server")
-	diffCmd.PersistentFlags().StringVarP(&diffp.application, "application", "a", "", "name of the application to get the config for")
-	diffCmd.PersistentFlags().StringVar(&diffp.profile, "profile", "default", "configuration profile")
-	diffCmd.PersistentFlags().StringVar(&diffp.label, "label", "master", "configuration label")
+	diffCmd.PersistentFlags().StringSliceVarP(&diffp.application, "application", "a", nil, "name of the application to get the config for, repeat the flag or use comma separated list for multiple applications")
+	diffCmd.PersistentFlags().StringSliceVar(&diffp.profile, "profile", []string{"default"}, "configuration profile, repeat the flag or use comma separated list for multiple profiles")
+	diffCmd.PersistentFlags().StringVar(&diffp.label, "label", "master", "configuration label, comma separated labels are tried in order e.g. 'feature/foo,master'")
 	diffCmd.PersistentFlags().StringVar(&diffp.targetLabel, "target-label", "", "second label to diff with")
-	diffCmd.PersistentFlags().StringVar(&diffp.targetProfile, "target-profile", "", "second profile to diff with, --profile value will be used, if not defined")
+	diffCmd.PersistentFlags().StringSliceVar(&diffp.targetProfile, "target-profile", nil, "second profile to diff with, --profile value will be used, if not defined")
+	diffCmd.PersistentFlags().Var(&diffp.ignore, "ignore", "key to exclude from the diff, might be a key glob 'server.*', regex '/.*\\.url$/' or JSON path '$.server.port', can be repeated")
+	diffCmd.PersistentFlags().StringVar(&diffp.ignoreFile, "ignore-file", defaultIgnoreFile, "file with ignore rules, one per line")
+	diffCmd.PersistentFlags().StringVar(&diffp.color, "color", colorAuto, "colorize the output, might be one of 'auto|always|never'")
+	diffCmd.PersistentFlags().BoolVar(&diffp.sideBySide, "side-by-side", false, "output the diff in two columns")
+	diffCmd.PersistentFlags().IntVar(&diffp.width, "width", 0, "output width used for side by side layout, terminal width is used if not defined")
 	_ = diffCmd.MarkPersistentFlagRequired("source")       // #nosec G104
 	_ = diffCmd.MarkPersistentFlagRequired("application")  // #nosec G104
 	_ = diffCmd.MarkPersistentFlagRequired("target-label") // #nosec G104
@@ -148,11 +148,11 @@ func TestExecuteDiffFiles(t *testing.T) {
 			}))
 			defer ts.Close()
 
-			diffp.application = tp.appName
-			diffp.profile = tp.profileA
+			diffp.application = []string{tp.appName}
+			diffp.profile = []string{tp.profileA}
 			diffp.label = tp.labelA
 			diffp.targetLabel = tp.labelB
-			diffp.targetProfile = tp.profileB
+			diffp.targetProfile = []string{tp.profileB}
 			diffp.source = ts.URL
 			diffp.files = tp.fileName
 
@@ -251,10 +251,10 @@ func TestExecuteDiffValues(t *testing.T) {
 			}))
 			defer ts.Close()
 
-			diffp.application = tp.appName
-			diffp.profile = tp.profileA
+			diffp.application = []string{tp.appName}
+			diffp.profile = []string{tp.profileA}
 			diffp.label = tp.labelA
-			diffp.targetProfile = tp.profileB
+			diffp.targetProfile = []string{tp.profileB}
 			diffp.targetLabel = tp.labelB
 			diffp.source = ts.URL
 			diffp.format = tp.format
@@ -284,3 +284,52 @@ func TestExecuteDiffValues(t *testing.T) {
 		}()
 	}
 }
+
+func TestExecuteDiffValuesIgnore(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/master/app-default.properties":
+			fmt.Fprintln(w, "foo=1\nserver.url=http://a\nbar=2")
+		case "/develop/app-default.properties":
+			fmt.Fprintln(w, "foo=1\nserver.url=http://b\nbar=3")
+		default:
+			t.Errorf("Unexpected call to '%s'", r.RequestURI)
+		}
+	}))
+	defer ts.Close()
+
+	diffp.application = []string{"app"}
+	diffp.profile = []string{"default"}
+	diffp.label = "master"
+	diffp.targetProfile = []string{"default"}
+	diffp.targetLabel = "develop"
+	diffp.source = ts.URL
+	diffp.format = "properties"
+	diffp.ignore = IgnoreRules{}
+	_ = diffp.ignore.Set("*.url")
+	defer func() { diffp.ignore = IgnoreRules{} }()
+
+	filename := "stdout"
+	old := os.Stdout
+	temp, _ := os.Create(filename)
+	os.Stdout = temp
+	defer func() {
+		temp.Close()
+		os.Stdout = old
+	}()
+
+	if err := ExecuteDiffValues(); err != nil {
+		t.Error("Execute failed with: ", err)
+	}
+
+	raw, err := os.ReadFile(filename)
+	defer os.Remove(filename)
+	if err != nil {
+		t.Error("Expected to download file: ", err)
+	}
+
+	expected := "@@ -1,2 +1,2 @@\n foo=1\n-bar=2\n+bar=3"
+	if response := strings.TrimRight(string(raw), "\n"); response != expected {
+		t.Errorf("Expected response: '%s' got '%s' instead.", expected, response)
+	}
+}
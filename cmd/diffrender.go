@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"golang.org/x/term"
+)
+
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+
+	diffContext       = 3
+	defaultDiffWidth  = 160
+	minimalDiffWidth  = 20
+	sideBySideDivider = 3
+)
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiHeader  = "\x1b[1m"
+	ansiHunk    = "\x1b[36m"
+	ansiDelete  = "\x1b[31m"
+	ansiInsert  = "\x1b[32m"
+	ansiDeleteW = "\x1b[7;31m"
+	ansiInsertW = "\x1b[7;32m"
+)
+
+var wordSplitter = regexp.MustCompile(`\w+|\s+|[^\w\s]`)
+
+// diffRenderer writes the differences of two line sequences in unified or side-by-side layout.
+type diffRenderer struct {
+	out        io.Writer
+	color      bool
+	sideBySide bool
+	width      int
+}
+
+// segment part of the rendered line, changed segments are highlighted.
+type segment struct {
+	text    string
+	changed bool
+}
+
+// newDiffRenderer creates renderer writing to stdout, mode is one of 'auto|always|never'.
+func newDiffRenderer(mode string, sideBySide bool, width int) (*diffRenderer, error) {
+	color, err := useColor(mode, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	if width <= 0 {
+		width = terminalWidth()
+	}
+
+	return &diffRenderer{
+		out:        os.Stdout,
+		color:      color,
+		sideBySide: sideBySide,
+		width:      width,
+	}, nil
+}
+
+func useColor(mode string, f *os.File) (bool, error) {
+	switch mode {
+	case colorAlways:
+		return true, nil
+	case colorNever:
+		return false, nil
+	case colorAuto, "":
+		if _, ok := os.LookupEnv("NO_COLOR"); ok {
+			return false, nil
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			return false, nil
+		}
+		return stat.Mode()&os.ModeCharDevice != 0, nil
+	default:
+		return false, fmt.Errorf("unknown color mode '%s' expected one of 'auto|always|never'", mode)
+	}
+}
+
+// terminalWidth width of the terminal on stdout, the COLUMNS env variable or the default width otherwise.
+func terminalWidth() int {
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width >= minimalDiffWidth {
+		return width
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns >= minimalDiffWidth {
+		return columns
+	}
+	return defaultDiffWidth
+}
+
+// Render writes diff of a and b preceded by header lines, nothing is written if there are no differences.
+func (r *diffRenderer) Render(a, b []string, header ...string) error {
+	if !r.color && !r.sideBySide {
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{A: a, B: b, Context: diffContext})
+		if err != nil || diff == "" {
+			return err
+		}
+		for _, h := range header {
+			if _, err := fmt.Fprintln(r.out, h); err != nil {
+				return err
+			}
+		}
+		_, err = fmt.Fprint(r.out, diff)
+		return err
+	}
+
+	groups := difflib.NewMatcher(a, b).GetGroupedOpCodes(diffContext)
+	if len(groups) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	for _, h := range header {
+		sb.WriteString(r.paint(h, ansiHeader))
+		sb.WriteString("\n")
+	}
+
+	for _, group := range groups {
+		sb.WriteString(r.paint(hunkHeader(group), ansiHunk))
+		sb.WriteString("\n")
+		for _, c := range group {
+			if r.sideBySide {
+				r.writeSideBySide(&sb, c, a, b)
+			} else {
+				r.writeUnified(&sb, c, a, b)
+			}
+		}
+	}
+
+	_, err := io.WriteString(r.out, sb.String())
+	return err
+}
+
+func (r *diffRenderer) writeUnified(sb *strings.Builder, c difflib.OpCode, a, b []string) {
+	if c.Tag == 'e' {
+		for _, line := range a[c.I1:c.I2] {
+			sb.WriteString(" " + trimEOL(line) + "\n")
+		}
+		return
+	}
+
+	deleted, inserted := r.highlight(c, a, b)
+	for _, segs := range deleted {
+		sb.WriteString(r.paintSegments(append([]segment{{text: "-"}}, segs...), ansiDelete, ansiDeleteW, -1))
+		sb.WriteString("\n")
+	}
+	for _, segs := range inserted {
+		sb.WriteString(r.paintSegments(append([]segment{{text: "+"}}, segs...), ansiInsert, ansiInsertW, -1))
+		sb.WriteString("\n")
+	}
+}
+
+func (r *diffRenderer) writeSideBySide(sb *strings.Builder, c difflib.OpCode, a, b []string) {
+	column := (r.width - sideBySideDivider) / 2
+	if column < 1 {
+		column = 1
+	}
+
+	if c.Tag == 'e' {
+		for i := c.I1; i < c.I2; i++ {
+			left := r.paintSegments([]segment{{text: trimEOL(a[i])}}, "", "", column)
+			right := r.paintSegments([]segment{{text: trimEOL(b[c.J1+i-c.I1])}}, "", "", column)
+			sb.WriteString(strings.TrimRight(left+"   "+right, " ") + "\n")
+		}
+		return
+	}
+
+	deleted, inserted := r.highlight(c, a, b)
+	rows := max(len(deleted), len(inserted))
+	for i := 0; i < rows; i++ {
+		divider := " | "
+		left := strings.Repeat(" ", column)
+		right := ""
+		switch {
+		case i >= len(inserted):
+			divider = " < "
+		case i >= len(deleted):
+			divider = " > "
+		}
+		if i < len(deleted) {
+			left = r.paintSegments(deleted[i], ansiDelete, ansiDeleteW, column)
+		}
+		if i < len(inserted) {
+			right = r.paintSegments(inserted[i], ansiInsert, ansiInsertW, column)
+		}
+		sb.WriteString(strings.TrimRight(left+divider+right, " ") + "\n")
+	}
+}
+
+// highlight splits changed lines into segments, lines replaced one by one get word level highlighting.
+func (r *diffRenderer) highlight(c difflib.OpCode, a, b []string) ([][]segment, [][]segment) {
+	deleted := make([][]segment, c.I2-c.I1)
+	inserted := make([][]segment, c.J2-c.J1)
+	for i := range deleted {
+		deleted[i] = []segment{{text: trimEOL(a[c.I1+i])}}
+	}
+	for j := range inserted {
+		inserted[j] = []segment{{text: trimEOL(b[c.J1+j])}}
+	}
+
+	if c.Tag == 'r' {
+		for i := 0; i < len(deleted) && i < len(inserted); i++ {
+			deleted[i], inserted[i] = wordDiff(trimEOL(a[c.I1+i]), trimEOL(b[c.J1+i]))
+		}
+	}
+	return deleted, inserted
+}
+
+// wordDiff splits both lines into words and marks the words which differ.
+func wordDiff(a, b string) ([]segment, []segment) {
+	wa := wordSplitter.FindAllString(a, -1)
+	wb := wordSplitter.FindAllString(b, -1)
+
+	var sa, sb []segment
+	for _, c := range difflib.NewMatcher(wa, wb).GetOpCodes() {
+		changed := c.Tag != 'e'
+		if c.I2 > c.I1 {
+			sa = append(sa, segment{text: strings.Join(wa[c.I1:c.I2], ""), changed: changed})
+		}
+		if c.J2 > c.J1 {
+			sb = append(sb, segment{text: strings.Join(wb[c.J1:c.J2], ""), changed: changed})
+		}
+	}
+	return sa, sb
+}
+
+// paintSegments renders segments in given colors, width >= 0 truncates or pads the visible text to the width.
+func (r *diffRenderer) paintSegments(segs []segment, base, changed string, width int) string {
+	var sb strings.Builder
+	visible := 0
+	for _, s := range segs {
+		text := strings.ReplaceAll(s.text, "\t", "    ")
+		if width >= 0 {
+			remaining := width - visible
+			if remaining <= 0 {
+				break
+			}
+			text = truncate(text, remaining)
+		}
+		visible += utf8.RuneCountInString(text)
+
+		if s.changed && r.color {
+			sb.WriteString(r.paint(text, changed))
+		} else {
+			sb.WriteString(r.paint(text, base))
+		}
+	}
+
+	if width > visible {
+		sb.WriteString(strings.Repeat(" ", width-visible))
+	}
+	return sb.String()
+}
+
+func (r *diffRenderer) paint(text, color string) string {
+	if !r.color || color == "" || text == "" {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+func truncate(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	return string([]rune(text)[:width])
+}
+
+func trimEOL(line string) string {
+	return strings.TrimRight(line, "\r\n")
+}
+
+func hunkHeader(group []difflib.OpCode) string {
+	first, last := group[0], group[len(group)-1]
+	return fmt.Sprintf("@@ -%s +%s @@", unifiedRange(first.I1, last.I2), unifiedRange(first.J1, last.J2))
+}
+
+// unifiedRange formats the range same way as difflib does.
+func unifiedRange(start, stop int) string {
+	beginning := start + 1
+	length := stop - start
+	if length == 1 {
+		return strconv.Itoa(beginning)
+	}
+	if length == 0 {
+		beginning--
+	}
+	return fmt.Sprintf("%d,%d", beginning, length)
+}
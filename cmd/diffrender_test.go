@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/wandera/scccmd/internal/testutil"
+)
+
+func TestDiffRenderer_Render(t *testing.T) {
+	a := difflib.SplitLines("foo: 1\nbar: 2\nbaz: 3")
+	b := difflib.SplitLines("foo: 1\nbar: 5\nbaz: 3\nqux: 4")
+
+	testParams := []struct {
+		name       string
+		color      bool
+		sideBySide bool
+		width      int
+		expected   string
+	}{
+		{
+			"plain",
+			false,
+			false,
+			0,
+			"hdr\n@@ -1,3 +1,4 @@\n foo: 1\n-bar: 2\n+bar: 5\n baz: 3\n+qux: 4\n",
+		},
+		{
+			"color",
+			true,
+			false,
+			0,
+			"\x1b[1mhdr\x1b[0m\n\x1b[36m@@ -1,3 +1,4 @@\x1b[0m\n foo: 1\n" +
+				"\x1b[31m-\x1b[0m\x1b[31mbar: \x1b[0m\x1b[7;31m2\x1b[0m\n" +
+				"\x1b[32m+\x1b[0m\x1b[32mbar: \x1b[0m\x1b[7;32m5\x1b[0m\n baz: 3\n" +
+				"\x1b[32m+\x1b[0m\x1b[32mqux: 4\x1b[0m\n",
+		},
+		{
+			"side-by-side",
+			false,
+			true,
+			23,
+			"hdr\n@@ -1,3 +1,4 @@\nfoo: 1       foo: 1\nbar: 2     | bar: 5\nbaz: 3       baz: 3\n           > qux: 4\n",
+		},
+	}
+
+	for _, tp := range testParams {
+		var out bytes.Buffer
+		r := diffRenderer{out: &out, color: tp.color, sideBySide: tp.sideBySide, width: tp.width}
+		if err := r.Render(a, b, "hdr"); err != nil {
+			t.Errorf("%s: Render failed with: %v", tp.name, err)
+		}
+		testutil.AssertString(t, tp.name, tp.expected, out.String())
+	}
+}
+
+func TestDiffRenderer_RenderEqual(t *testing.T) {
+	for _, sideBySide := range []bool{false, true} {
+		var out bytes.Buffer
+		r := diffRenderer{out: &out, color: true, sideBySide: sideBySide, width: 80}
+		if err := r.Render(difflib.SplitLines("foo"), difflib.SplitLines("foo"), "hdr"); err != nil {
+			t.Errorf("Render failed with: %v", err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("Expected no output for equal content got '%s' instead", out.String())
+		}
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	if _, err := useColor("sometimes", nil); err == nil {
+		t.Error("Unknown color mode expected to fail")
+	}
+	if c, _ := useColor(colorAlways, nil); !c {
+		t.Error("Color expected to be enabled")
+	}
+	if c, _ := useColor(colorNever, nil); c {
+		t.Error("Color expected to be disabled")
+	}
+}
+
+func TestTerminalWidth(t *testing.T) {
+	// stdout redirected to a pipe is not a terminal, COLUMNS is used instead
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close() // nolint: errcheck
+	defer w.Close() // nolint: errcheck
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	t.Setenv("COLUMNS", "100")
+	if width := terminalWidth(); width != 100 {
+		t.Errorf("Expected width from COLUMNS 100 got %d", width)
+	}
+	t.Setenv("COLUMNS", "")
+	if width := terminalWidth(); width != defaultDiffWidth {
+		t.Errorf("Expected default width %d got %d", defaultDiffWidth, width)
+	}
+}
@@ -33,7 +33,7 @@ func ExecuteEncrypt() error {
 		}
 	}
 
-	res, err := client.NewClient(client.Config{
+	res, err := newClient(client.Config{
 		URI: ep.source,
 	}).Encrypt(ep.value)
 
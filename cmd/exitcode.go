@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+// Exit codes of the tool, scripts might use them to tell missing config from unavailable server.
+const (
+	ExitOK                = 0
+	ExitError             = 1
+	ExitNotFound          = 3
+	ExitInvalidLabel      = 4
+	ExitUnauthorized      = 5
+	ExitServerUnavailable = 6
+	ExitDecryptionFailed  = 7
+	ExitPinMismatch       = 8
+)
+
+// exitCodes exit codes of the client errors, the first matching error wins if the command failed with multiple errors.
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{client.ErrServerUnavailable, ExitServerUnavailable},
+	{client.ErrUnauthorized, ExitUnauthorized},
+	{client.ErrDecryptionFailed, ExitDecryptionFailed},
+	{ErrPinMismatch, ExitPinMismatch},
+	{client.ErrInvalidLabel, ExitInvalidLabel},
+	{client.ErrNotFound, ExitNotFound},
+}
+
+// ExitCode exit code of the tool for the error returned from Execute.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	for _, c := range exitCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ExitError
+}
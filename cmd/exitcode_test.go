@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+func TestExitCode(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/default/missing/file":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"status":404,"error":"Not Found","message":"No such label: missing"}`))
+		case "/app/default/master/file":
+			w.WriteHeader(http.StatusNotFound)
+		case "/app/default/master/private":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/app/default/master/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/decrypt":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	fetch := func(label string, file string) error {
+		_, err := newClient(client.Config{
+			URI:          ts.URL,
+			Applications: []string{"app"},
+			Profiles:     []string{"default"},
+			Label:        label,
+		}).FetchFileE(file)
+		return fmt.Errorf("unable to get file %s: %w", file, err)
+	}
+	_, decryptErr := newClient(client.Config{URI: ts.URL}).Decrypt("value")
+
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"ok", nil, ExitOK},
+		{"generic", errors.New("invalid flag"), ExitError},
+		{"not found", fetch("master", "file"), ExitNotFound},
+		{"invalid label", fetch("missing", "file"), ExitInvalidLabel},
+		{"unauthorized", fetch("master", "private"), ExitUnauthorized},
+		{"server down", fetch("master", "down"), ExitServerUnavailable},
+		{"server error", fetch("master", "error"), ExitError},
+		{"decryption", decryptErr, ExitDecryptionFailed},
+		{"pin mismatch", fmt.Errorf("%w: version differs", ErrPinMismatch), ExitPinMismatch},
+		{"joined", errors.Join(fetch("master", "file"), fetch("master", "down")), ExitServerUnavailable},
+	}
+
+	for _, tt := range tests {
+		if code := ExitCode(tt.err); code != tt.expected {
+			t.Errorf("%s: expected exit code %d got %d instead (%v)", tt.name, tt.expected, code, tt.err)
+		}
+	}
+}
@@ -14,6 +14,9 @@ const (
 type FileMapping struct {
 	source      string
 	destination string
+	mode        string
+	owner       string
+	checksum    bool
 }
 
 // FileMappings file mappings source:dest,source:dest...
@@ -72,3 +75,25 @@ func (m *FileMappings) Destinations() []string {
 
 	return destinations
 }
+
+// fileOptions options of the written file, unset values are taken from defaults.
+func (m FileMapping) fileOptions(defaults fileOptions) (fileOptions, error) {
+	return overrideFileOptions(defaults, m.mode, m.owner, m.checksum)
+}
+
+func overrideFileOptions(defaults fileOptions, mode string, owner string, checksum bool) (fileOptions, error) {
+	opts := defaults
+	if mode != "" {
+		m, err := parseFileMode(mode)
+		if err != nil {
+			return opts, err
+		}
+		opts.mode = m
+	}
+	if owner != "" {
+		opts.owner = owner
+	}
+	opts.checksum = opts.checksum || checksum
+
+	return opts, nil
+}
@@ -1,37 +1,80 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/wandera/scccmd/pkg/client"
 )
 
 const stdoutPlaceholder = "-"
 
 var gp = struct {
-	source       string
-	application  string
-	profile      string
-	label        string
-	format       string
-	destination  string
-	fileMappings FileMappings
+	source               string
+	application          []string
+	profile              []string
+	label                string
+	format               string
+	destination          string
+	fileMappings         FileMappings
+	manifest             string
+	concurrency          int
+	allOrNothing         bool
+	fileMode             string
+	owner                string
+	checksum             bool
+	listing              string
+	indexFile            string
+	binary               bool
+	defaultLabel         bool
+	maxSize              int64
+	cacheDir             string
+	maxStale             time.Duration
+	resolve              bool
+	watchListen          string
+	watchInterval        time.Duration
+	pin                  string
+	pinStrategy          string
+	pinUpdate            bool
+	provenance           string
+	provenanceAnnotation string
 }{}
 
 var getCmd = &cobra.Command{
 	Use:   "get",
 	Short: "Get the config from the given config server",
+	Long: `Get the config from the given config server.
+Use one of the subcommands, or --manifest to get the config of multiple applications described by a manifest file.`,
+	PersistentPreRunE: validateGetParams,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if gp.manifest == "" {
+			return cmd.Help()
+		}
+		m, err := LoadManifest(gp.manifest)
+		if err != nil {
+			return err
+		}
+		return runWatched(m.watchConfig(), ExecuteGetManifest)
+	},
 }
 
 var getValuesCmd = &cobra.Command{
 	Use:   "values",
 	Short: "Get the config values in specified format from the given config server",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return ExecuteGetValues()
+		return runWatched(getClientConfig(), ExecuteGetValues)
 	},
 }
 
@@ -39,34 +82,177 @@ var getFilesCmd = &cobra.Command{
 	Use:   "files",
 	Short: "Get the config files from the given config server",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return ExecuteGetFiles()
+		return runWatched(getClientConfig(), ExecuteGetFiles)
 	},
 }
 
+func validateGetParams(cmd *cobra.Command, args []string) error {
+	err := rootCmd.PersistentPreRunE(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	if cmd.HasSubCommands() {
+		return nil
+	}
+
+	var missing []string
+	if gp.source == "" {
+		missing = append(missing, `"source"`)
+	}
+	if len(gp.application) == 0 {
+		missing = append(missing, `"application"`)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("required flag(s) %s not set", strings.Join(missing, ", "))
+	}
+	c := getClientConfig()
+	return c.Validate()
+}
+
+func getClientConfig() client.Config {
+	return clientConfig(client.Config{URI: gp.source, Profiles: gp.profile, Applications: gp.application, Label: gp.label})
+}
+
 // ExecuteGetValues runs get values cmd.
 func ExecuteGetValues() error {
-	ext, err := client.ParseExtension(gp.format)
+	opts, err := defaultFileOptions()
+	if err != nil {
+		return err
+	}
+
+	pin, err := newPinner(getPinOptions())
+	if err != nil {
+		return err
+	}
+
+	c, pinned, err := pin.client(getClientConfig())
+	if err != nil {
+		return err
+	}
+
+	if err := getValues(c, pinned, gp.format, gp.destination, opts); err != nil {
+		return err
+	}
+	return pin.save()
+}
+
+// ExecuteGetFiles runs get files cmd.
+func ExecuteGetFiles() error {
+	opts, err := defaultFileOptions()
+	if err != nil {
+		return err
+	}
+
+	pin, err := newPinner(getPinOptions())
+	if err != nil {
+		return err
+	}
+
+	c, pinned, err := pin.client(getClientConfig())
+	if err != nil {
+		return err
+	}
+
+	if err := getFiles(c, pinned, gp.fileMappings.Mappings(), opts); err != nil {
+		return err
+	}
+	return pin.save()
+}
+
+// ExecuteGetManifest runs get cmd with the manifest file.
+func ExecuteGetManifest() error {
+	m, err := LoadManifest(gp.manifest)
+	if err != nil {
+		return err
+	}
+
+	defaults, err := defaultFileOptions()
+	if err != nil {
+		return err
+	}
+
+	pin, err := newPinner(getPinOptions())
+	if err != nil {
+		return err
+	}
+
+	for _, e := range m.Applications {
+		c, pinned, err := pin.client(clientConfig(m.ClientConfig(e)))
+		if err != nil {
+			return err
+		}
+		log.Debugf("Getting config for application %s, profile %s, label %s", strings.Join(c.Config().Applications, ","), strings.Join(c.Config().Profiles, ","), c.Config().Label)
+
+		if err := getFiles(c, pinned, e.FileMappings(), defaults); err != nil {
+			return err
+		}
+
+		for _, v := range e.Values {
+			opts, err := v.fileOptions(defaults)
+			if err != nil {
+				return err
+			}
+
+			if err := getValues(c, pinned, v.Format, v.Destination, opts); err != nil {
+				return err
+			}
+		}
+	}
+	return pin.save()
+}
+
+// clientConfig applies the file fetching flags to the client config.
+func clientConfig(c client.Config) client.Config {
+	c.Binary = gp.binary
+	c.UseDefaultLabel = gp.defaultLabel
+	c.MaxFileSize = gp.maxSize
+	c.CacheDir = gp.cacheDir
+	c.CacheMaxStale = gp.maxStale
+	return c
+}
+
+func defaultFileOptions() (fileOptions, error) {
+	mode, err := parseFileMode(gp.fileMode)
+	if err != nil {
+		return fileOptions{}, err
+	}
+
+	return fileOptions{mode: mode, owner: gp.owner, checksum: gp.checksum}, nil
+}
+
+func getValues(c client.Client, pin *pinned, format string, destination string, opts fileOptions) error {
+	ext, err := client.ParseExtension(format)
 	if err != nil {
 		return err
 	}
 
-	resp, err := client.
-		NewClient(client.Config{URI: gp.source, Profile: gp.profile, Application: gp.application, Label: gp.label}).
-		FetchAs(ext)
+	resp, err := c.FetchAs(ext)
 	if err != nil {
 		return err
 	}
 
-	if gp.destination != "" {
+	// the server response is pinned, resolved placeholders might depend on the local environment
+	sum := sha256.Sum256([]byte(resp))
+	if err := pin.verify("values."+string(ext), hex.EncodeToString(sum[:])); err != nil {
+		return err
+	}
+
+	if gp.resolve {
+		if resp, err = resolvePlaceholders(resp, format); err != nil {
+			return fmt.Errorf("unable to resolve placeholders: %v", err)
+		}
+	}
+
+	if destination != "" {
 		log.Debug("Config server response:")
 		log.Debug(resp)
 
-		// #nosec G306
-		if err = os.WriteFile(gp.destination, []byte(resp), 0o644); err != nil {
+		if err = writeFile(destination, []byte(resp), opts); err != nil {
 			return err
 		}
 
-		log.Debug("Response written to: ", gp.destination)
+		log.Debug("Response written to: ", destination)
 	} else {
 		fmt.Print(resp)
 	}
@@ -74,48 +260,194 @@ func ExecuteGetValues() error {
 	return nil
 }
 
-// ExecuteGetFiles runs get files cmd.
-func ExecuteGetFiles() error {
-	for _, mapping := range gp.fileMappings.Mappings() {
-		resp, err := client.
-			NewClient(client.Config{URI: gp.source, Profile: gp.profile, Application: gp.application, Label: gp.label}).
-			FetchFileE(strings.TrimSpace(mapping.source))
-		if err != nil {
+func getFiles(c client.Client, pin *pinned, mappings []FileMapping, defaults fileOptions) error {
+	mappings, err := expandMappings(c, mappings, gp.listing, gp.indexFile)
+	if err != nil {
+		return err
+	}
+
+	outputs, errs := fetchFiles(c, mappings, defaults, gp.concurrency)
+	if err := verifyOutputs(pin, mappings, outputs); err != nil {
+		log.Debug("Not writing any file, some of the files do not match the lockfile")
+		abortOutputs(outputs)
+		return err
+	}
+	if gp.allOrNothing {
+		if err := errors.Join(errs...); err != nil {
+			log.Debug("Not writing any file, some of the files cannot be retrieved")
+			abortOutputs(outputs)
 			return err
 		}
+	}
 
-		log.Debug("Config server response:")
-		log.Debug(string(resp))
+	for i, mapping := range mappings {
+		if outputs[i] == nil {
+			continue
+		}
+
+		if err := outputs[i].Commit(); err != nil {
+			errs[i] = err
+			continue
+		}
 
 		if mapping.destination == stdoutPlaceholder {
-			_, _ = os.Stdout.Write(resp) // #nosec G104
-			fmt.Println()
 			log.Debug("Response written to stdout")
 		} else {
-			// #nosec G306
-			if err = os.WriteFile(mapping.destination, resp, 0o644); err != nil {
-				return err
-			}
-
 			log.Debug("Response written to: ", mapping.destination)
 		}
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+// verifyOutputs verifies the checksums of the fetched files against the lockfile.
+func verifyOutputs(pin *pinned, mappings []FileMapping, outputs []fileOutput) error {
+	var errs []error
+	for i, out := range outputs {
+		if out == nil {
+			continue
+		}
+		errs = append(errs, pin.verify(strings.TrimSpace(mappings[i].source), out.Checksum()))
+	}
+	return errors.Join(errs...)
+}
+
+func abortOutputs(outputs []fileOutput) {
+	for _, out := range outputs {
+		if out != nil {
+			out.Abort()
+		}
+	}
+}
+
+// fileOutput destination of the fetched file, content is not visible until committed.
+type fileOutput interface {
+	io.Writer
+	Commit() error
+	Abort()
+
+	// Checksum hex encoded sha256 of the content written so far
+	Checksum() string
+}
+
+// stdoutOutput buffers the content so the outputs of concurrent fetches are not interleaved.
+type stdoutOutput struct {
+	bytes.Buffer
+}
+
+// Commit writes the buffered content to stdout.
+func (o *stdoutOutput) Commit() error {
+	_, err := o.WriteTo(os.Stdout)
+	return err
+}
+
+// Abort discards the buffered content.
+func (o *stdoutOutput) Abort() {
+	o.Reset()
+}
+
+// Checksum hex encoded sha256 of the buffered content.
+func (o *stdoutOutput) Checksum() string {
+	sum := sha256.Sum256(o.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+// fetchFiles streams all the mappings into uncommitted outputs using at most concurrency parallel requests,
+// outputs and errors are returned in the order of mappings, output is nil if the fetch failed.
+func fetchFiles(c client.Client, mappings []FileMapping, defaults fileOptions, concurrency int) ([]fileOutput, []error) {
+	outputs := make([]fileOutput, len(mappings))
+	errs := make([]error, len(mappings))
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, mapping := range mappings {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			source := strings.TrimSpace(mapping.source)
+			out, err := newFileOutput(mapping, defaults)
+			if err != nil {
+				errs[i] = fmt.Errorf("unable to get file %s: %w", source, err)
+				return
+			}
+
+			n, err := c.FetchFileTo(context.Background(), source, out)
+			if err != nil {
+				out.Abort()
+				errs[i] = fmt.Errorf("unable to get file %s: %w", source, err)
+				return
+			}
+
+			log.Debugf("Config server response for %s: %d bytes", source, n)
+			outputs[i] = out
+		}()
+	}
+	wg.Wait()
+
+	return outputs, errs
+}
+
+func newFileOutput(mapping FileMapping, defaults fileOptions) (fileOutput, error) {
+	if mapping.destination == stdoutPlaceholder {
+		return &stdoutOutput{}, nil
+	}
+
+	opts, err := mapping.fileOptions(defaults)
+	if err != nil {
+		return nil, err
+	}
+	return newPendingFile(mapping.destination, opts)
 }
 
 func init() {
 	getCmd.AddCommand(getFilesCmd)
 	getCmd.AddCommand(getValuesCmd)
 	getCmd.PersistentFlags().StringVarP(&gp.source, "source", "s", "", "address of the config server")
-	getCmd.PersistentFlags().StringVarP(&gp.application, "application", "a", "", "name of the application to get the config for")
-	getCmd.PersistentFlags().StringVarP(&gp.profile, "profile", "p", "default", "configuration profile")
-	getCmd.PersistentFlags().StringVarP(&gp.label, "label", "l", "master", "configuration label")
-	_ = getCmd.MarkPersistentFlagRequired("source")      // #nosec G104
-	_ = getCmd.MarkPersistentFlagRequired("application") // #nosec G104
+	getCmd.PersistentFlags().StringSliceVarP(&gp.application, "application", "a", nil, "name of the application to get the config for, repeat the flag or use comma separated list for multiple applications, later applications take precedence")
+	getCmd.PersistentFlags().StringSliceVarP(&gp.profile, "profile", "p", []string{"default"}, "configuration profile, repeat the flag or use comma separated list for multiple profiles, later profiles take precedence")
+	getCmd.PersistentFlags().StringVarP(&gp.label, "label", "l", "master", "configuration label, comma separated labels are tried in order e.g. 'feature/foo,master'")
+	getCmd.PersistentFlags().StringVar(&gp.fileMode, "file-mode", defaultFileMode, "permissions of the written files in octal notation")
+	getCmd.PersistentFlags().StringVar(&gp.owner, "owner", "", "owner of the written files in form of user[:group], names or numeric ids might be used")
+	getCmd.PersistentFlags().StringVar(&gp.cacheDir, "cache-dir", "", "directory of the local response cache, cached copy is used when the config server is unavailable")
+	getCmd.PersistentFlags().DurationVar(&gp.maxStale, "cache-max-stale", 0, "maximum age of the cached copy used when the config server is unavailable, 0 means unlimited")
+	getCmd.PersistentFlags().BoolVar(&gp.checksum, "checksum", false, "write sha256 checksum of every written file next to it into <destination>.sha256")
+	getCmd.PersistentFlags().StringVar(&gp.watchListen, "watch-listen", "", "keep running and get the config again on change notifications received on the address e.g. ':8080', "+
+		"accepts config server '/monitor' webhooks and Spring Cloud Bus refresh events")
+	getCmd.PersistentFlags().StringVar(&gp.pin, "pin", "", "lockfile pinning the config version and content checksums, written if it does not exist, "+
+		"the config is refused if it does not match the lockfile")
+	getCmd.PersistentFlags().StringVar(&gp.pinStrategy, "pin-strategy", pinStrategyLabel, "how the pinned config is got, might be one of 'label|verify', "+
+		"'label' uses the pinned version (commit SHA) as the label, 'verify' uses --label and fails if the version differs")
+	getCmd.PersistentFlags().BoolVar(&gp.pinUpdate, "pin-update", false, "pin the current config version and content checksums, the lockfile is rewritten")
+	getCmd.PersistentFlags().StringVar(&gp.provenance, "provenance", "", "write the provenance of the config (source, application, profile, label, version, fetch time and checksums) "+
+		"into the JSON file, the file might be used as --pin lockfile to get the same config again")
+	getCmd.PersistentFlags().StringVar(&gp.provenanceAnnotation, "provenance-annotation", "", "annotate the pod the command runs in with the provenance, "+
+		"the pod is given by POD_NAME and POD_NAMESPACE environment variables, requires permission to patch the pod")
+	getCmd.PersistentFlags().DurationVar(&gp.watchInterval, "watch-interval", 0, "keep running and get the config again periodically, 0 disables the polling")
+
+	getCmd.Flags().StringVarP(&gp.manifest, "manifest", "m", "", "manifest file describing the config of multiple applications to get")
 
-	getFilesCmd.Flags().VarP(&gp.fileMappings, "files", "f", "files to get in form of source:destination pairs, you can use - as a output to stdout, example '--files application.yaml:config.yaml'")
+	getFilesCmd.Flags().VarP(&gp.fileMappings, "files", "f", "files to get in form of source:destination pairs, you can use - as a output to stdout, example '--files application.yaml:config.yaml', "+
+		"source might be a glob or a directory with destination being a directory, example '--files nginx/*.conf:/etc/nginx/conf.d/'")
 	_ = getFilesCmd.MarkFlagRequired("files") // #nosec G104
+	for _, flags := range []*pflag.FlagSet{getFilesCmd.Flags(), getCmd.Flags()} {
+		flags.IntVar(&gp.concurrency, "concurrency", 4, "maximum number of files fetched in parallel")
+		flags.BoolVar(&gp.allOrNothing, "all-or-nothing", false, "write the files only if all of them were fetched successfully")
+		flags.StringVar(&gp.listing, "listing", listingIndex, "source used to list files matched by glob or directory mappings, might be one of 'index|environment'")
+		flags.StringVar(&gp.indexFile, "index-file", defaultIndexFile, "name of the index file listing the files of the directory, used by 'index' listing")
+		flags.BoolVar(&gp.binary, "binary", false, "get the files as binary (application/octet-stream), content is byte exact without placeholders resolved by the server")
+		flags.BoolVar(&gp.defaultLabel, "use-default-label", false, "get the files from the default label of the server, --label is ignored")
+		flags.Int64Var(&gp.maxSize, "max-size", 0, "maximum size of a single file in bytes, 0 means unlimited")
+	}
 
 	getValuesCmd.Flags().StringVarP(&gp.format, "format", "f", "yaml", "output format might be one of 'json|yaml|properties'")
 	getValuesCmd.Flags().StringVarP(&gp.destination, "destination", "d", "", "destination file name")
+	for _, flags := range []*pflag.FlagSet{getValuesCmd.Flags(), getCmd.Flags()} {
+		flags.BoolVar(&gp.resolve, "resolve-placeholders", false, "resolve '${key:default}' placeholders in the values using the config itself and the environment variables")
+	}
 }
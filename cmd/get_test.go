@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/wandera/scccmd/internal/testutil"
 )
 
 func TestNoArgGetExecute(t *testing.T) {
@@ -75,8 +81,8 @@ func TestExecuteGetFiles(t *testing.T) {
 			}))
 			defer ts.Close()
 
-			gp.application = tp.appName
-			gp.profile = tp.profile
+			gp.application = []string{tp.appName}
+			gp.profile = []string{tp.profile}
 			gp.label = tp.label
 			gp.source = ts.URL
 			gp.fileMappings = FileMappings{mappings: make([]FileMapping, 1)}
@@ -110,6 +116,11 @@ func TestExecuteGetFiles(t *testing.T) {
 			if response := strings.TrimRight(string(raw[:]), "\n"); response != tp.testContent {
 				t.Errorf("Expected response: '%s' got '%s' instead.", tp.testContent, response)
 			}
+
+			// the files written by the init container are read by the application containers running as other users
+			if info, err := os.Stat(filename); err == nil && tp.destFileName != stdoutPlaceholder && info.Mode().Perm() != 0o644 {
+				t.Errorf("Expected mode 0644 got %o instead", info.Mode().Perm())
+			}
 		}()
 	}
 }
@@ -164,8 +175,8 @@ func TestExecuteGetValues(t *testing.T) {
 			}))
 			defer ts.Close()
 
-			gp.application = tp.appName
-			gp.profile = tp.profile
+			gp.application = []string{tp.appName}
+			gp.profile = []string{tp.profile}
 			gp.label = tp.label
 			gp.source = ts.URL
 			gp.destination = tp.destFileName
@@ -187,3 +198,133 @@ func TestExecuteGetValues(t *testing.T) {
 		}()
 	}
 }
+
+func TestExecuteGetFilesPartialFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/app/default/master/ok":
+			fmt.Fprint(w, "ok")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	for _, allOrNothing := range []bool{false, true} {
+		func() {
+			dir := t.TempDir()
+			gp.application = []string{"app"}
+			gp.profile = []string{"default"}
+			gp.label = "master"
+			gp.source = ts.URL
+			gp.concurrency = 2
+			gp.allOrNothing = allOrNothing
+			defer func() { gp.allOrNothing = false }()
+			gp.fileMappings = FileMappings{mappings: []FileMapping{
+				{source: "ok", destination: filepath.Join(dir, "ok")},
+				{source: "missing1", destination: filepath.Join(dir, "missing1")},
+				{source: "missing2", destination: filepath.Join(dir, "missing2")},
+			}}
+
+			err := ExecuteGetFiles()
+			if err == nil {
+				t.Fatal("Execute expected to fail")
+			}
+			for _, src := range []string{"missing1", "missing2"} {
+				if !strings.Contains(err.Error(), src) {
+					t.Errorf("Expected error to report '%s' got '%v' instead", src, err)
+				}
+			}
+
+			_, err = os.Stat(filepath.Join(dir, "ok"))
+			if allOrNothing && err == nil {
+				t.Error("No file expected to be written in all-or-nothing mode")
+			}
+			if !allOrNothing && err != nil {
+				t.Error("Expected successfully fetched file to be written: ", err)
+			}
+		}()
+	}
+}
+
+func TestExecuteGetFilesConcurrency(t *testing.T) {
+	var inFlight, maxInFlight int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if current <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, r.RequestURI)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	gp.application = []string{"app"}
+	gp.profile = []string{"default"}
+	gp.label = "master"
+	gp.source = ts.URL
+	gp.concurrency = 3
+	gp.fileMappings = FileMappings{}
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("file%d", i)
+		gp.fileMappings.mappings = append(gp.fileMappings.mappings, FileMapping{source: name, destination: filepath.Join(dir, name)})
+	}
+
+	if err := ExecuteGetFiles(); err != nil {
+		t.Fatal("Execute failed with: ", err)
+	}
+
+	if maxInFlight > 3 {
+		t.Errorf("Expected at most 3 parallel requests got %d instead", maxInFlight)
+	}
+
+	for i := 0; i < 10; i++ {
+		raw, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("file%d", i)))
+		if err != nil {
+			t.Error("Expected to download file: ", err)
+		}
+		testutil.AssertString(t, "Content mismatch", fmt.Sprintf("/app/default/master/file%d", i), string(raw))
+	}
+}
+
+func TestExecuteGetFilesBinaryStdout(t *testing.T) {
+	content := []byte{0x00, 0xff, 0x0a}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	defer ts.Close()
+
+	gp.application = []string{"app"}
+	gp.profile = []string{"default"}
+	gp.label = "master"
+	gp.source = ts.URL
+	gp.binary = true
+	defer func() { gp.binary = false }()
+	gp.fileMappings = FileMappings{mappings: []FileMapping{{source: "keystore.jks", destination: stdoutPlaceholder}}}
+
+	filename := filepath.Join(t.TempDir(), "stdout")
+	old := os.Stdout
+	temp, _ := os.Create(filename)
+	os.Stdout = temp
+	defer func() {
+		temp.Close()
+		os.Stdout = old
+	}()
+
+	if err := ExecuteGetFiles(); err != nil {
+		t.Fatal("Execute failed with: ", err)
+	}
+
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, content) {
+		t.Errorf("Expected byte exact output %v got %v instead", content, raw)
+	}
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+const (
+	listingIndex       = "index"
+	listingEnvironment = "environment"
+
+	defaultIndexFile = ".scccmdindex"
+	globMetaChars    = "*?["
+)
+
+// isPattern true if the source describes multiple files, either glob e.g. 'nginx/*.conf' or directory e.g. 'nginx/'.
+func isPattern(source string) bool {
+	return strings.HasSuffix(source, "/") || strings.ContainsAny(source, globMetaChars)
+}
+
+// patternBase directory part of the pattern preceding the first segment containing glob characters.
+func patternBase(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, s := range segments {
+		if strings.ContainsAny(s, globMetaChars) {
+			return strings.Join(segments[:i], "/")
+		}
+	}
+	return strings.TrimSuffix(pattern, "/")
+}
+
+// matchPattern checks the file against the glob or directory pattern.
+func matchPattern(pattern, file string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(file, pattern) && file != pattern
+	}
+
+	ok, err := path.Match(pattern, file)
+	return err == nil && ok
+}
+
+// expandMappings replaces every glob or directory mapping by the mappings of all the matching files,
+// destination of such mapping is a directory, matched files keep their path relative to the pattern base.
+func expandMappings(c client.Client, mappings []FileMapping, listing string, indexFile string) ([]FileMapping, error) {
+	var expanded []FileMapping
+	for _, mapping := range mappings {
+		source := strings.TrimSpace(mapping.source)
+		if !isPattern(source) {
+			expanded = append(expanded, mapping)
+			continue
+		}
+
+		files, err := listFiles(c, source, listing, indexFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list files matching %s: %w", source, err)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no files matching %s found", source)
+		}
+		log.Debugf("Files matching %s: %v", source, files)
+
+		base := patternBase(source)
+		for _, file := range files {
+			m := mapping
+			m.source = file
+			if mapping.destination != stdoutPlaceholder {
+				rel := strings.TrimPrefix(strings.TrimPrefix(file, base), "/")
+				m.destination = filepath.Join(mapping.destination, filepath.FromSlash(rel))
+			}
+			expanded = append(expanded, m)
+		}
+	}
+	return expanded, nil
+}
+
+// listFiles lists all the files matching the pattern, using the listing source one of 'index|environment'.
+func listFiles(c client.Client, pattern string, listing string, indexFile string) ([]string, error) {
+	var candidates []string
+	var err error
+	switch listing {
+	case listingIndex, "":
+		candidates, err = listIndex(c, patternBase(pattern), indexFile)
+	case listingEnvironment:
+		candidates, err = listEnvironment(c, pattern)
+	default:
+		err = fmt.Errorf("unknown listing source '%s' expected one of 'index|environment'", listing)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var files []string
+	for _, file := range candidates {
+		if !seen[file] && matchPattern(pattern, file) {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// listIndex reads the index file located in the pattern base directory,
+// index contains paths relative to the directory one per line, lines starting with '#' are ignored.
+func listIndex(c client.Client, base string, indexFile string) ([]string, error) {
+	index := path.Join(base, indexFile)
+	resp, err := c.FetchFileE(index)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	scanner := bufio.NewScanner(strings.NewReader(string(resp)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		files = append(files, path.Join(base, line))
+	}
+	return files, scanner.Err()
+}
+
+// listEnvironment derives the files from the names of the environment property sources,
+// every suffix of the property source name is considered to be a candidate.
+func listEnvironment(c client.Client, pattern string) ([]string, error) {
+	env, err := c.FetchEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, ps := range env.PropertySources {
+		name := ps.Name
+		// e.g. "Config resource 'file [/repo/nginx/app.yml]' via location 'file:/repo/nginx/'"
+		if start, end := strings.Index(name, "["), strings.Index(name, "]"); start >= 0 && end > start {
+			name = name[start+1 : end]
+		}
+		if i := strings.Index(name, "://"); i >= 0 {
+			name = name[i+3:]
+		}
+		for i := -1; i < len(name); i++ {
+			if i >= 0 && name[i] != '/' && name[i] != ':' {
+				continue
+			}
+			if candidate := name[i+1:]; matchPattern(pattern, candidate) {
+				files = append(files, candidate)
+				break
+			}
+		}
+	}
+	return files, nil
+}
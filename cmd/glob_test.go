@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+)
+
+func TestMatchPattern(t *testing.T) {
+	testParams := []struct {
+		pattern string
+		file    string
+		match   bool
+	}{
+		{"nginx/*.conf", "nginx/a.conf", true},
+		{"nginx/*.conf", "nginx/sub/a.conf", false},
+		{"nginx/*.conf", "nginx/a.yaml", false},
+		{"nginx/", "nginx/sub/a.conf", true},
+		{"nginx/", "nginx/", false},
+		{"*.yaml", "app.yaml", true},
+	}
+
+	for _, tp := range testParams {
+		if got := matchPattern(tp.pattern, tp.file); got != tp.match {
+			t.Errorf("Pattern '%s' matching '%s' expected %v got %v instead", tp.pattern, tp.file, tp.match, got)
+		}
+	}
+}
+
+func TestPatternBase(t *testing.T) {
+	testutil.AssertString(t, "Incorrect base", "nginx", patternBase("nginx/*.conf"))
+	testutil.AssertString(t, "Incorrect base", "nginx", patternBase("nginx/"))
+	testutil.AssertString(t, "Incorrect base", "a/b", patternBase("a/b/*/c.conf"))
+	testutil.AssertString(t, "Incorrect base", "", patternBase("*.yaml"))
+}
+
+func TestExecuteGetFilesGlob(t *testing.T) {
+	testParams := []struct {
+		listing string
+		source  string
+		files   map[string]string
+	}{
+		{
+			listingIndex,
+			"nginx/*.conf",
+			map[string]string{"a.conf": "a", "b.conf": "b"},
+		},
+		{
+			listingIndex,
+			"nginx/",
+			map[string]string{"a.conf": "a", "b.conf": "b", "readme.md": "readme", "sub/c.conf": "c"},
+		},
+		{
+			listingEnvironment,
+			"nginx/*.conf",
+			map[string]string{"a.conf": "a"},
+		},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/app/default/master/nginx/" + defaultIndexFile:
+			fmt.Fprint(w, "# nginx files\na.conf\nb.conf\nreadme.md\nsub/c.conf\n")
+		case "/app/default/master":
+			fmt.Fprint(w, `{"name":"app","propertySources":[{"name":"file:/repo/nginx/a.conf","source":{}},{"name":"file:/repo/app.yml","source":{}}]}`)
+		case "/app/default/master/nginx/a.conf":
+			fmt.Fprint(w, "a")
+		case "/app/default/master/nginx/b.conf":
+			fmt.Fprint(w, "b")
+		case "/app/default/master/nginx/readme.md":
+			fmt.Fprint(w, "readme")
+		case "/app/default/master/nginx/sub/c.conf":
+			fmt.Fprint(w, "c")
+		default:
+			t.Errorf("Unexpected call to '%s'", r.RequestURI)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	for _, tp := range testParams {
+		dir := t.TempDir()
+		gp.application = []string{"app"}
+		gp.profile = []string{"default"}
+		gp.label = "master"
+		gp.source = ts.URL
+		gp.listing = tp.listing
+		gp.indexFile = defaultIndexFile
+		gp.fileMappings = FileMappings{mappings: []FileMapping{{source: tp.source, destination: dir}}}
+
+		if err := ExecuteGetFiles(); err != nil {
+			t.Fatal("Execute failed with: ", err)
+		}
+
+		for file, content := range tp.files {
+			raw, err := os.ReadFile(filepath.Join(dir, file))
+			if err != nil {
+				t.Error("Expected to download file: ", err)
+			}
+			testutil.AssertString(t, "Content mismatch", content, string(raw))
+		}
+
+		entries, _ := os.ReadDir(dir)
+		if tp.source == "nginx/*.conf" && len(entries) != len(tp.files) {
+			t.Errorf("Expected %d files got %v instead", len(tp.files), entries)
+		}
+	}
+}
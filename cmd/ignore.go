@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultIgnoreFile = ".scccmdignore"
+	ignoreComment     = "#"
+	jsonPathRoot      = "$"
+)
+
+var jsonPathBracket = regexp.MustCompile(`\[['"]([^'"]*)['"]\]`)
+
+// IgnoreRules set of key patterns excluded from the diff output.
+//
+// Every rule is one of:
+//   - a key glob, e.g. 'server.*' or 'eureka.**', where '*' matches a single key segment and '**' any number of segments
+//   - a regular expression enclosed in slashes, e.g. '/.*\.url$/'
+//   - a JSON path, e.g. '$.spring.datasource.url' or "$['server']['port']"
+type IgnoreRules struct {
+	rules    []string
+	patterns []*regexp.Regexp
+}
+
+func (r *IgnoreRules) String() string {
+	return strings.Join(r.rules, ",")
+}
+
+// Set parse single ignore rule from string.
+func (r *IgnoreRules) Set(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fmt.Errorf("empty ignore rule")
+	}
+
+	pattern, err := compileIgnoreRule(value)
+	if err != nil {
+		return err
+	}
+
+	r.rules = append(r.rules, value)
+	r.patterns = append(r.patterns, pattern)
+	return nil
+}
+
+// Type type name (for cobra).
+func (r *IgnoreRules) Type() string {
+	return "IgnoreRules"
+}
+
+// Rules all rules.
+func (r *IgnoreRules) Rules() []string {
+	return r.rules
+}
+
+// Empty true if there are no rules.
+func (r *IgnoreRules) Empty() bool {
+	return len(r.patterns) == 0
+}
+
+// Load reads rules from the ignore file, one rule per line, lines starting with '#' are ignored.
+func (r *IgnoreRules) Load(filename string) error {
+	f, err := os.Open(filename) // #nosec G304
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint: errcheck
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		rule := strings.TrimSpace(scanner.Text())
+		if rule == "" || strings.HasPrefix(rule, ignoreComment) {
+			continue
+		}
+		if err := r.Set(rule); err != nil {
+			return fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Matches true if the flattened key (e.g. 'a.b[0].c') matches any of the rules.
+func (r *IgnoreRules) Matches(key string) bool {
+	for _, p := range r.patterns {
+		if p.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter removes all ignored keys from the content in given format (one of 'json|yaml|yml|properties').
+// Content in any other format is returned unchanged.
+func (r *IgnoreRules) Filter(content []byte, format string) ([]byte, error) {
+	if r.Empty() || len(bytes.TrimSpace(content)) == 0 {
+		return content, nil
+	}
+
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "json":
+		return r.filterJSON(content)
+	case "yaml", "yml":
+		return r.filterYAML(content)
+	case "properties":
+		return r.filterProperties(content), nil
+	default:
+		return content, nil
+	}
+}
+
+// FilterFile removes all ignored keys from the file content, format is derived from the file extension.
+func (r *IgnoreRules) FilterFile(content []byte, filename string) ([]byte, error) {
+	return r.Filter(content, filepath.Ext(filename))
+}
+
+// filterJSON removes the ignored keys keeping the key order, content is returned unchanged if no key is ignored.
+func (r *IgnoreRules) filterJSON(content []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if !r.prune("", &doc) {
+		return content, nil
+	}
+
+	var out bytes.Buffer
+	if err := writeJSONNode(&out, &doc, ""); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// filterYAML removes the ignored keys from every document keeping the key order and comments,
+// content is returned unchanged if no key is ignored.
+func (r *IgnoreRules) filterYAML(content []byte) ([]byte, error) {
+	var docs []*yaml.Node
+	pruned := false
+	d := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc yaml.Node
+		if err := d.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		pruned = r.prune("", &doc) || pruned
+		docs = append(docs, &doc)
+	}
+	if !pruned {
+		return content, nil
+	}
+
+	var out bytes.Buffer
+	e := yaml.NewEncoder(&out)
+	e.SetIndent(2)
+	for _, doc := range docs {
+		if err := e.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (r *IgnoreRules) filterProperties(content []byte) []byte {
+	var out bytes.Buffer
+	skipping := false
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		continued := strings.HasSuffix(trimmed, `\`)
+
+		if skipping {
+			skipping = continued
+			continue
+		}
+
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "!") &&
+			r.Matches(propertyKey(trimmed)) {
+			skipping = continued
+			continue
+		}
+
+		out.WriteString(line)
+	}
+	return out.Bytes()
+}
+
+// prune removes the ignored keys from the node in place, returns true if any key was removed.
+func (r *IgnoreRules) prune(prefix string, node *yaml.Node) bool {
+	pruned := false
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			pruned = r.prune(prefix, n) || pruned
+		}
+	case yaml.MappingNode:
+		content := make([]*yaml.Node, 0, len(node.Content))
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := joinKey(prefix, node.Content[i].Value)
+			if r.Matches(key) {
+				pruned = true
+				continue
+			}
+			pruned = r.prune(key, node.Content[i+1]) || pruned
+			content = append(content, node.Content[i], node.Content[i+1])
+		}
+		node.Content = content
+	case yaml.SequenceNode:
+		content := make([]*yaml.Node, 0, len(node.Content))
+		for i, n := range node.Content {
+			key := prefix + "[" + strconv.Itoa(i) + "]"
+			if r.Matches(key) {
+				pruned = true
+				continue
+			}
+			pruned = r.prune(key, n) || pruned
+			content = append(content, n)
+		}
+		node.Content = content
+	}
+	return pruned
+}
+
+// writeJSONNode writes the node parsed from JSON back as indented JSON in the original key order.
+func writeJSONNode(out *bytes.Buffer, node *yaml.Node, indent string) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			if err := writeJSONNode(out, n, indent); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, closing, step := "[", "]", 1
+		if node.Kind == yaml.MappingNode {
+			open, closing, step = "{", "}", 2
+		}
+		out.WriteString(open)
+		for i := 0; i < len(node.Content); i += step {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			out.WriteString("\n" + indent + "  ")
+			if step == 2 {
+				if err := writeJSONNode(out, node.Content[i], indent+"  "); err != nil {
+					return err
+				}
+				out.WriteString(": ")
+			}
+			if err := writeJSONNode(out, node.Content[i+step-1], indent+"  "); err != nil {
+				return err
+			}
+		}
+		if len(node.Content) > 0 {
+			out.WriteString("\n" + indent)
+		}
+		out.WriteString(closing)
+	case yaml.ScalarNode:
+		if node.Tag != "!!str" {
+			out.WriteString(node.Value)
+			return nil
+		}
+		value, err := json.Marshal(node.Value)
+		if err != nil {
+			return err
+		}
+		out.Write(value)
+	default:
+		return fmt.Errorf("unsupported JSON node at line %d", node.Line)
+	}
+	return nil
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// propertyKey extracts key from the single properties line, key ends with first unescaped '=', ':' or whitespace.
+func propertyKey(line string) string {
+	var key strings.Builder
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+			continue
+		case c == '=' || c == ':' || c == ' ' || c == '\t':
+			return key.String()
+		}
+		key.WriteRune(c)
+	}
+	return key.String()
+}
+
+func compileIgnoreRule(rule string) (*regexp.Regexp, error) {
+	if len(rule) > 1 && strings.HasPrefix(rule, "/") && strings.HasSuffix(rule, "/") {
+		pattern, err := regexp.Compile(rule[1 : len(rule)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore rule '%s': %v", rule, err)
+		}
+		return pattern, nil
+	}
+
+	if strings.HasPrefix(rule, jsonPathRoot) {
+		rule = jsonPathToKey(rule)
+		if rule == "" {
+			return nil, fmt.Errorf("invalid ignore rule '%s': json path selects the whole document", jsonPathRoot)
+		}
+	}
+
+	return regexp.Compile("^" + globToRegexp(rule) + "$")
+}
+
+// jsonPathToKey converts JSON path into flattened key glob e.g. "$.a['b'][0].*" into "a.b[0].*".
+func jsonPathToKey(path string) string {
+	key := strings.TrimPrefix(path, jsonPathRoot)
+	key = jsonPathBracket.ReplaceAllString(key, ".$1")
+	return strings.TrimPrefix(key, ".")
+}
+
+func globToRegexp(glob string) string {
+	var re strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				re.WriteString(".*")
+				i++
+			} else {
+				re.WriteString(`[^.\[]*`)
+			}
+		case '?':
+			re.WriteString(`[^.\[]`)
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return re.String()
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+)
+
+func TestIgnoreRules_Matches(t *testing.T) {
+	testParams := []struct {
+		rule  string
+		key   string
+		match bool
+	}{
+		{"server.port", "server.port", true},
+		{"server.port", "server.portal", false},
+		{"server.*", "server.port", true},
+		{"server.*", "server.ssl.enabled", false},
+		{"server.**", "server.ssl.enabled", true},
+		{"*.url", "datasource.url", true},
+		{"hosts[?]", "hosts[1]", true},
+		{"/.*\\.url$/", "spring.datasource.url", true},
+		{"/.*\\.url$/", "spring.datasource.urls", false},
+		{"$.spring.datasource.url", "spring.datasource.url", true},
+		{"$['server']['port']", "server.port", true},
+		{"$.hosts[0].name", "hosts[0].name", true},
+		{"$.hosts[0].name", "hosts[1].name", false},
+	}
+
+	for _, tp := range testParams {
+		rules := IgnoreRules{}
+		if err := rules.Set(tp.rule); err != nil {
+			t.Errorf("Rule '%s' failed to parse: %v", tp.rule, err)
+			continue
+		}
+
+		if got := rules.Matches(tp.key); got != tp.match {
+			t.Errorf("Rule '%s' matching '%s' expected %v got %v instead", tp.rule, tp.key, tp.match, got)
+		}
+	}
+}
+
+func TestIgnoreRules_SetInvalid(t *testing.T) {
+	for _, rule := range []string{"", "/[/", "$"} {
+		rules := IgnoreRules{}
+		if err := rules.Set(rule); err == nil {
+			t.Errorf("Rule '%s' expected to fail", rule)
+		}
+	}
+}
+
+func TestIgnoreRules_Filter(t *testing.T) {
+	testParams := []struct {
+		rules    []string
+		format   string
+		content  string
+		expected string
+	}{
+		{
+			[]string{"server.port"},
+			"yaml",
+			"server:\n  port: 8080\n  host: foo\nname: app\n",
+			"server:\n  host: foo\nname: app\n",
+		},
+		{
+			[]string{"hosts[1]"},
+			"yml",
+			"hosts:\n- a\n- b\n- c\n",
+			"hosts:\n  - a\n  - c\n",
+		},
+		{
+			[]string{"server.port"},
+			"yaml",
+			"# app config\nzone: eu # primary\nserver:\n  host: foo\n  port: 8080\nname: app\n",
+			"# app config\nzone: eu # primary\nserver:\n  host: foo\nname: app\n",
+		},
+		{
+			[]string{"server.port"},
+			"yaml",
+			"# not touched\nname:   app\nhosts:\n- a\n",
+			"# not touched\nname:   app\nhosts:\n- a\n",
+		},
+		{
+			[]string{"server.port"},
+			"yaml",
+			"server:\n  port: 1\n  host: a\n---\nspring:\n  profiles: prod\nserver:\n  port: 2\n",
+			"server:\n  host: a\n---\nspring:\n  profiles: prod\nserver: {}\n",
+		},
+		{
+			[]string{"$.server.port"},
+			"json",
+			"{\"zone\":\"eu\",\"server\":{\"port\":8080,\"host\":\"foo\",\"tags\":[1.50,true,null,\"\\u00e9\"]},\"empty\":{}}",
+			"{\n  \"zone\": \"eu\",\n  \"server\": {\n    \"host\": \"foo\",\n    \"tags\": [\n      1.50,\n      true,\n      null,\n      \"é\"\n    ]\n  },\n  \"empty\": {}\n}\n",
+		},
+		{
+			[]string{"$.server.port"},
+			"json",
+			"{\"name\":  \"app\"}",
+			"{\"name\":  \"app\"}",
+		},
+		{
+			[]string{"$.server.port"},
+			"json",
+			"{\"server\":{\"port\":8080,\"host\":\"foo\"}}",
+			"{\n  \"server\": {\n    \"host\": \"foo\"\n  }\n}\n",
+		},
+		{
+			[]string{"*.url"},
+			"properties",
+			"datasource.url=jdbc:foo\\\n  bar\n# comment\ndatasource.user=sa\n",
+			"# comment\ndatasource.user=sa\n",
+		},
+		{
+			[]string{"foo"},
+			".txt",
+			"foo=bar",
+			"foo=bar",
+		},
+	}
+
+	for _, tp := range testParams {
+		rules := IgnoreRules{}
+		for _, rule := range tp.rules {
+			if err := rules.Set(rule); err != nil {
+				t.Fatalf("Rule '%s' failed to parse: %v", rule, err)
+			}
+		}
+
+		got, err := rules.Filter([]byte(tp.content), tp.format)
+		if err != nil {
+			t.Errorf("Filter failed with: %v", err)
+			continue
+		}
+
+		testutil.AssertString(t, "Filtered content mismatch", tp.expected, string(got))
+	}
+}
+
+func TestIgnoreRules_Load(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), defaultIgnoreFile)
+	if err := os.WriteFile(filename, []byte("# comment\n\nserver.port\n/.*\\.url$/\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := IgnoreRules{}
+	if err := rules.Load(filename); err != nil {
+		t.Fatalf("Load failed with: %v", err)
+	}
+
+	if len(rules.Rules()) != 2 {
+		t.Errorf("Expected 2 rules got %v instead", rules.Rules())
+	}
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wandera/scccmd/pkg/inject"
+)
+
+const stdinPlaceholder = "-"
+
+var ip = struct {
+	configFile string
+	filename   string
+	output     string
+	namespace  string
+}{}
+
+var injectCmd = &cobra.Command{
+	Use:   "inject",
+	Short: "Inject the config init container into Kubernetes manifests",
+	Long: `Inject the config init container into Kubernetes manifests the same way the webhook does on admission.
+Pods, Deployments, StatefulSets, DaemonSets, ReplicaSets, Jobs and CronJobs are injected, other documents are written unchanged.
+The webhook configuration file and the pod annotations configure the init container, the same as for the webhook.`,
+	Example: `  scccmd inject -c config/config.yaml -f deployment.yaml -o deployment-injected.yaml
+  kustomize build . | scccmd inject -c config/config.yaml | kubectl apply -f -`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ExecuteInject()
+	},
+}
+
+// ExecuteInject runs inject cmd.
+func ExecuteInject() error {
+	config, err := inject.LoadConfig(ip.configFile)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if ip.filename != stdinPlaceholder {
+		f, err := os.Open(ip.filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint: errcheck
+		r = f
+	}
+
+	if ip.output == "" {
+		return inject.InjectManifests(r, os.Stdout, ip.namespace, config)
+	}
+
+	var out bytes.Buffer
+	if err := inject.InjectManifests(r, &out, ip.namespace, config); err != nil {
+		return err
+	}
+	return writeFile(ip.output, out.Bytes(), fileOptions{mode: 0o644})
+}
+
+func init() {
+	injectCmd.Flags().StringVarP(&ip.configFile, "config-file", "c", "config/config.yaml", "the webhook configuration file")
+	injectCmd.Flags().StringVarP(&ip.filename, "filename", "f", stdinPlaceholder, "manifests to inject, - reads from stdin")
+	injectCmd.Flags().StringVarP(&ip.namespace, "namespace", "n", "default", "namespace of the manifests, used if the manifest does not specify one")
+	injectCmd.Flags().StringVarP(&ip.output, "output", "o", "", "file the injected manifests are written to, stdout is used if empty")
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecuteInject(t *testing.T) {
+	dir := t.TempDir()
+	ip.configFile = filepath.Join(dir, "config.yaml")
+	ip.filename = filepath.Join(dir, "pod.yaml")
+	ip.output = filepath.Join(dir, "out", "pod.yaml")
+	defer func() { ip.filename, ip.output = stdinPlaceholder, "" }()
+
+	_ = os.WriteFile(ip.configFile, []byte("container-image: scccmd:test\n"), 0o600)
+	_ = os.WriteFile(ip.filename, []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+  annotations:
+    config.scccmd.github.com/destination: /config/app.yaml
+spec:
+  containers:
+  - name: app
+`), 0o600)
+
+	if err := ExecuteInject(); err != nil {
+		t.Fatal("Execute failed with: ", err)
+	}
+
+	raw, err := os.ReadFile(ip.output)
+	if err != nil {
+		t.Fatal("Expected output written: ", err)
+	}
+	if !strings.Contains(string(raw), "image: scccmd:test") {
+		t.Errorf("Expected init container injected got:\n%s", raw)
+	}
+}
+
+func TestExecuteInjectMissingConfig(t *testing.T) {
+	ip.configFile = filepath.Join(t.TempDir(), "missing.yaml")
+	if err := ExecuteInject(); err == nil {
+		t.Error("Expected error for missing webhook config")
+	}
+}
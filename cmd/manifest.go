@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/wandera/scccmd/pkg/client"
+	"gopkg.in/yaml.v2"
+)
+
+// Manifest declarative description of all the configuration to get, see 'get --manifest'.
+type Manifest struct {
+	Source       string          `yaml:"source,omitempty"`
+	Profile      string          `yaml:"profile,omitempty"`
+	Label        string          `yaml:"label,omitempty"`
+	Applications []ManifestEntry `yaml:"applications"`
+}
+
+// ManifestEntry single application to get the configuration for,
+// empty source, profile and label are inherited from the Manifest.
+// Application and profile might be comma separated lists, later items take precedence.
+type ManifestEntry struct {
+	Application string           `yaml:"application"`
+	Source      string           `yaml:"source,omitempty"`
+	Profile     string           `yaml:"profile,omitempty"`
+	Label       string           `yaml:"label,omitempty"`
+	Files       []ManifestFile   `yaml:"files,omitempty"`
+	Values      []ManifestValues `yaml:"values,omitempty"`
+}
+
+// ManifestFile config file to get, you can use - as a destination to output to stdout.
+// Mode, owner and checksum override the values given by command flags.
+type ManifestFile struct {
+	Source      string `yaml:"source"`
+	Destination string `yaml:"destination"`
+	Mode        string `yaml:"mode,omitempty"`
+	Owner       string `yaml:"owner,omitempty"`
+	Checksum    bool   `yaml:"checksum,omitempty"`
+}
+
+// ManifestValues config values to get in specified format, empty destination outputs to stdout.
+// Mode, owner and checksum override the values given by command flags.
+type ManifestValues struct {
+	Format      string `yaml:"format,omitempty"`
+	Destination string `yaml:"destination,omitempty"`
+	Mode        string `yaml:"mode,omitempty"`
+	Owner       string `yaml:"owner,omitempty"`
+	Checksum    bool   `yaml:"checksum,omitempty"`
+}
+
+// UnmarshalYAML implements Unmarshaler interface for Manifest.
+func (m *Manifest) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type rawManifest Manifest
+	raw := rawManifest{
+		Profile: "default",
+		Label:   "master",
+	}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	*m = Manifest(raw)
+	return nil
+}
+
+// UnmarshalYAML implements Unmarshaler interface for ManifestValues.
+func (v *ManifestValues) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type rawManifestValues ManifestValues
+	raw := rawManifestValues{
+		Format: "yaml",
+	}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	*v = ManifestValues(raw)
+	return nil
+}
+
+// LoadManifest reads and validates the manifest file.
+func LoadManifest(filename string) (*Manifest, error) {
+	data, err := os.ReadFile(filename) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, fmt.Errorf("unable to parse manifest %s: %v", filename, err)
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %v", filename, err)
+	}
+
+	return &m, nil
+}
+
+// Validate checks that all the entries are complete.
+func (m *Manifest) Validate() error {
+	if len(m.Applications) == 0 {
+		return fmt.Errorf("no applications defined")
+	}
+
+	for i, e := range m.Applications {
+		c := m.ClientConfig(e)
+		switch {
+		case len(c.Applications) == 0:
+			return fmt.Errorf("applications[%d]: application is required", i)
+		case c.URI == "":
+			return fmt.Errorf("applications[%d]: source is required", i)
+		case len(c.Profiles) == 0:
+			return fmt.Errorf("applications[%d]: profile is required", i)
+		case len(e.Files) == 0 && len(e.Values) == 0:
+			return fmt.Errorf("applications[%d]: one of files or values should be specified", i)
+		}
+
+		for j, f := range e.Files {
+			if f.Source == "" || f.Destination == "" {
+				return fmt.Errorf("applications[%d].files[%d]: both source and destination are required", i, j)
+			}
+			if f.Mode != "" {
+				if _, err := parseFileMode(f.Mode); err != nil {
+					return fmt.Errorf("applications[%d].files[%d]: %v", i, j, err)
+				}
+			}
+		}
+
+		for j, v := range e.Values {
+			if _, err := client.ParseExtension(v.Format); err != nil {
+				return fmt.Errorf("applications[%d].values[%d]: %v", i, j, err)
+			}
+			if v.Mode != "" {
+				if _, err := parseFileMode(v.Mode); err != nil {
+					return fmt.Errorf("applications[%d].values[%d]: %v", i, j, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ClientConfig client config of the entry with defaults taken from the Manifest.
+func (m *Manifest) ClientConfig(e ManifestEntry) client.Config {
+	c := client.Config{
+		URI:          e.Source,
+		Applications: client.SplitList(e.Application),
+		Profiles:     client.SplitList(e.Profile),
+		Label:        e.Label,
+	}
+
+	if c.URI == "" {
+		c.URI = m.Source
+	}
+	if len(c.Profiles) == 0 {
+		c.Profiles = client.SplitList(m.Profile)
+	}
+	if c.Label == "" {
+		c.Label = m.Label
+	}
+
+	return c
+}
+
+// watchConfig applications and profiles of all the manifest entries.
+func (m *Manifest) watchConfig() client.Config {
+	var c client.Config
+	for _, e := range m.Applications {
+		ec := m.ClientConfig(e)
+		for _, a := range ec.Applications {
+			if !slices.Contains(c.Applications, a) {
+				c.Applications = append(c.Applications, a)
+			}
+		}
+		for _, p := range ec.Profiles {
+			if !slices.Contains(c.Profiles, p) {
+				c.Profiles = append(c.Profiles, p)
+			}
+		}
+	}
+	return c
+}
+
+// FileMappings file mappings of the entry.
+func (e ManifestEntry) FileMappings() []FileMapping {
+	mappings := make([]FileMapping, len(e.Files))
+	for i, f := range e.Files {
+		mappings[i] = FileMapping{
+			source:      f.Source,
+			destination: f.Destination,
+			mode:        f.Mode,
+			owner:       f.Owner,
+			checksum:    f.Checksum,
+		}
+	}
+
+	return mappings
+}
+
+func (v ManifestValues) fileOptions(defaults fileOptions) (fileOptions, error) {
+	return overrideFileOptions(defaults, v.Mode, v.Owner, v.Checksum)
+}
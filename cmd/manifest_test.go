@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+)
+
+func TestLoadManifest(t *testing.T) {
+	testParams := []struct {
+		content string
+		valid   bool
+	}{
+		{
+			"source: http://localhost\napplications:\n- application: app\n  files:\n  - source: a:b,c.yaml\n    destination: /tmp/a:b,c.yaml\n",
+			true,
+		},
+		{
+			"applications:\n- application: app\n  source: http://localhost\n  values:\n  - destination: values.yaml\n",
+			true,
+		},
+		{
+			"source: http://localhost\napplications: []\n",
+			false,
+		},
+		{
+			"applications:\n- application: app\n  values:\n  - destination: values.yaml\n",
+			false,
+		},
+		{
+			"source: http://localhost\napplications:\n- application: app\n",
+			false,
+		},
+		{
+			"source: http://localhost\napplications:\n- application: app\n  values:\n  - format: xml\n",
+			false,
+		},
+		{
+			"source: http://localhost\napplications:\n- application: app\n  files:\n  - source: a.yaml\n",
+			false,
+		},
+		{
+			"source: http://localhost\nunknown: true\napplications:\n- application: app\n  values:\n  - format: json\n",
+			false,
+		},
+	}
+
+	dir := t.TempDir()
+	for i, tp := range testParams {
+		filename := filepath.Join(dir, fmt.Sprintf("manifest-%d.yaml", i))
+		if err := os.WriteFile(filename, []byte(tp.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := LoadManifest(filename)
+		if tp.valid && err != nil {
+			t.Errorf("Manifest %d expected to be valid, got: %v", i, err)
+		}
+		if !tp.valid && err == nil {
+			t.Errorf("Manifest %d expected to be invalid", i)
+		}
+	}
+}
+
+func TestManifest_ClientConfig(t *testing.T) {
+	m := Manifest{Source: "http://localhost", Profile: "default, cloud", Label: "master"}
+
+	testParams := []struct {
+		entry        ManifestEntry
+		applications string
+		profiles     string
+	}{
+		{ManifestEntry{Application: "app"}, "app", "default|cloud"},
+		{ManifestEntry{Application: "app,common", Profile: "eu"}, "app|common", "eu"},
+	}
+
+	for _, tp := range testParams {
+		c := m.ClientConfig(tp.entry)
+		testutil.AssertString(t, "Incorrect applications", tp.applications, strings.Join(c.Applications, "|"))
+		testutil.AssertString(t, "Incorrect profiles", tp.profiles, strings.Join(c.Profiles, "|"))
+	}
+}
+
+func TestExecuteGetManifest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/app/default/master/application.yaml":
+			fmt.Fprint(w, "app-file")
+		case "/develop/app2-prod.json":
+			fmt.Fprint(w, "app2-values")
+		default:
+			t.Errorf("Unexpected call to '%s'", r.RequestURI)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	manifest := fmt.Sprintf(`source: %s
+applications:
+- application: app
+  files:
+  - source: application.yaml
+    destination: %s
+- application: app2
+  profile: prod
+  label: develop
+  values:
+  - format: json
+    destination: %s
+`, ts.URL, filepath.Join(dir, "app.yaml"), filepath.Join(dir, "app2.json"))
+
+	gp.manifest = filepath.Join(dir, "scccmd.yaml")
+	defer func() { gp.manifest = "" }()
+	if err := os.WriteFile(gp.manifest, []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExecuteGetManifest(); err != nil {
+		t.Fatal("Execute failed with: ", err)
+	}
+
+	for file, content := range map[string]string{"app.yaml": "app-file", "app2.json": "app2-values"} {
+		raw, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Error("Expected to download file: ", err)
+		}
+		testutil.AssertString(t, "Content mismatch", content, string(raw))
+	}
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wandera/scccmd/pkg/client"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// pinStrategyLabel gets the config using the pinned version as the label, e.g. the commit SHA
+	pinStrategyLabel = "label"
+	// pinStrategyVerify gets the config using the configured label and fails if the version differs
+	pinStrategyVerify = "verify"
+
+	checksumPrefix = "sha256:"
+	lockfileMode   = 0o644
+)
+
+// ErrPinMismatch returned when the config does not match the version or checksums pinned in the lockfile.
+var ErrPinMismatch = errors.New("config does not match the lockfile")
+
+// Lockfile config versions and content checksums pinned by 'get --pin', the same format is used by the provenance file.
+type Lockfile struct {
+	Entries []*LockEntry `yaml:"entries" json:"entries"`
+}
+
+// LockEntry pinned config of the application, profile and label, application and profile might be comma separated lists.
+// Version is the Environment version returned by the server, e.g. the commit SHA, empty if the backend does not have one.
+// Checksums are sha256 of the fetched files and values keyed by the file source or 'values.<format>'.
+type LockEntry struct {
+	Source      string            `yaml:"source" json:"source"`
+	Application string            `yaml:"application" json:"application"`
+	Profile     string            `yaml:"profile" json:"profile"`
+	Label       string            `yaml:"label" json:"label"`
+	Version     string            `yaml:"version,omitempty" json:"version,omitempty"`
+	Fetched     time.Time         `yaml:"fetched" json:"fetched"`
+	Checksums   map[string]string `yaml:"checksums,omitempty" json:"checksums,omitempty"`
+}
+
+// newLockEntry creates the entry of the config fetched in the version.
+func newLockEntry(c client.Config, version string) *LockEntry {
+	return &LockEntry{
+		Source:      c.URI,
+		Application: strings.Join(c.Applications, ","),
+		Profile:     strings.Join(c.Profiles, ","),
+		Label:       c.Label,
+		Version:     version,
+		Fetched:     time.Now().UTC().Truncate(time.Second),
+		Checksums:   map[string]string{},
+	}
+}
+
+// LoadLockfile reads the lockfile, missing file is an empty Lockfile.
+func LoadLockfile(path string) (*Lockfile, bool, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if errors.Is(err, os.ErrNotExist) {
+		return &Lockfile{}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var l Lockfile
+	if err := yaml.UnmarshalStrict(data, &l); err != nil {
+		return nil, false, fmt.Errorf("unable to parse lockfile %s: %v", path, err)
+	}
+	return &l, true, nil
+}
+
+// find returns the index of the config entry, -1 if not pinned yet.
+func (l *Lockfile) find(c client.Config) int {
+	for i, e := range l.Entries {
+		if e.Source == c.URI && e.Application == strings.Join(c.Applications, ",") &&
+			e.Profile == strings.Join(c.Profiles, ",") && e.Label == c.Label {
+			return i
+		}
+	}
+	return -1
+}
+
+// pinOptions options of the config pinning and provenance recording.
+type pinOptions struct {
+	lockfile             string
+	strategy             string
+	update               bool
+	provenance           string
+	provenanceAnnotation string
+}
+
+// pinner pins the config of a single get run to the lockfile and records its provenance,
+// nil pinner does not pin nor record anything.
+type pinner struct {
+	opts       pinOptions
+	lockfile   *Lockfile
+	changed    bool
+	provenance *Lockfile
+}
+
+// newPinner loads the lockfile, returns nil if neither the lockfile nor the provenance file is set.
+func newPinner(opts pinOptions) (*pinner, error) {
+	if opts.lockfile == "" && opts.provenance == "" {
+		return nil, nil
+	}
+
+	p := &pinner{opts: opts}
+	if opts.provenance != "" {
+		p.provenance = &Lockfile{}
+	}
+	if opts.lockfile == "" {
+		return p, nil
+	}
+
+	if opts.strategy != pinStrategyLabel && opts.strategy != pinStrategyVerify {
+		return nil, fmt.Errorf("invalid pin strategy '%s', might be one of '%s|%s'", opts.strategy, pinStrategyLabel, pinStrategyVerify)
+	}
+	l, exists, err := LoadLockfile(opts.lockfile)
+	if err != nil {
+		return nil, err
+	}
+	p.lockfile, p.changed = l, !exists
+	return p, nil
+}
+
+// getPinOptions pinning options given by command flags.
+func getPinOptions() pinOptions {
+	return pinOptions{
+		lockfile:             gp.pin,
+		strategy:             gp.pinStrategy,
+		update:               gp.pinUpdate,
+		provenance:           gp.provenance,
+		provenanceAnnotation: gp.provenanceAnnotation,
+	}
+}
+
+// client creates the client of the config, the config is pinned to the locked version,
+// or the version is recorded if the config is not pinned yet or the lockfile is being updated.
+func (p *pinner) client(c client.Config) (client.Client, *pinned, error) {
+	if p == nil {
+		return newClient(c), nil, nil
+	}
+
+	configured := c
+	var locked *LockEntry
+	i := -1
+	if p.lockfile != nil {
+		if i = p.lockfile.find(c); i >= 0 && !p.opts.update {
+			locked = p.lockfile.Entries[i]
+		}
+	}
+	if locked != nil && p.opts.strategy == pinStrategyLabel && locked.Version != "" {
+		c.Label = locked.Version
+	}
+
+	cl := newClient(c)
+	var version string
+	env, err := cl.FetchEnvironment()
+	switch {
+	case err == nil:
+		version = env.Version
+	case p.lockfile == nil:
+		// the provenance only records the version, it must not fail the get which does not need it
+		log.Warnf("Unable to get config version of %s, provenance is recorded without it: %v", c.Applications, err)
+	default:
+		return nil, nil, fmt.Errorf("unable to get config version: %w", err)
+	}
+	if locked != nil && version != locked.Version {
+		return nil, nil, fmt.Errorf("%w: version of %s is '%s', pinned '%s'", ErrPinMismatch, locked.Application, version, locked.Version)
+	}
+
+	current := newLockEntry(configured, version)
+	if p.provenance != nil {
+		p.provenance.Entries = append(p.provenance.Entries, current)
+	}
+	switch {
+	case locked != nil:
+		log.Debugf("Config of %s pinned to version '%s'", locked.Application, locked.Version)
+	case p.lockfile == nil:
+	case i >= 0:
+		p.lockfile.Entries[i], p.changed = current, true
+	default:
+		p.lockfile.Entries, p.changed = append(p.lockfile.Entries, current), true
+		log.Debugf("Pinning config of %s to version '%s'", current.Application, current.Version)
+	}
+	return cl, &pinned{current: current, locked: locked}, nil
+}
+
+// save writes the lockfile if it was changed and the provenance file.
+func (p *pinner) save() error {
+	if p == nil {
+		return nil
+	}
+
+	if p.lockfile != nil && p.changed {
+		data, err := yaml.Marshal(p.lockfile)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(p.opts.lockfile, data, fileOptions{mode: lockfileMode}); err != nil {
+			return err
+		}
+		log.Debug("Lockfile written to: ", p.opts.lockfile)
+	}
+
+	if p.provenance != nil {
+		return writeProvenance(p.opts.provenance, p.opts.provenanceAnnotation, p.provenance)
+	}
+	return nil
+}
+
+// pinned entries of a single config, nil pinned does not verify anything.
+type pinned struct {
+	// current entry of the fetched config
+	current *LockEntry
+	// locked entry of the lockfile, nil if the config is being pinned
+	locked *LockEntry
+}
+
+// verify records the checksum of the content and compares it with the locked one.
+func (p *pinned) verify(name string, checksum string) error {
+	if p == nil {
+		return nil
+	}
+
+	checksum = checksumPrefix + checksum
+	p.current.Checksums[name] = checksum
+	if p.locked == nil {
+		return nil
+	}
+
+	locked, ok := p.locked.Checksums[name]
+	if !ok {
+		return fmt.Errorf("%w: %s of %s is not pinned", ErrPinMismatch, name, p.locked.Application)
+	}
+	if locked != checksum {
+		return fmt.Errorf("%w: %s of %s has checksum %s, pinned %s", ErrPinMismatch, name, p.locked.Application, checksum, locked)
+	}
+	return nil
+}
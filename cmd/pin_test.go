@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"github.com/wandera/scccmd/pkg/configtest"
+)
+
+func TestExecuteGetValuesPin(t *testing.T) {
+	s := configtest.NewServer()
+	defer s.Close()
+	s.AddProperties("app", "default", "", map[string]interface{}{"foo": "bar"})
+	s.SetVersion("", "abc")
+	// the commit the master points to, addressable by its SHA as the label
+	s.AddProperties("app", "default", "abc", map[string]interface{}{"foo": "bar"})
+	s.SetVersion("abc", "abc")
+
+	dir := t.TempDir()
+	lockfile := filepath.Join(dir, "scccmd.lock")
+	destination := filepath.Join(dir, "config.yaml")
+	gp.application = []string{"app"}
+	gp.profile = []string{"default"}
+	gp.label = "master"
+	gp.source = s.URL
+	gp.format = "yaml"
+	gp.destination = destination
+	gp.pin = lockfile
+	defer func() {
+		gp.pin, gp.pinStrategy, gp.pinUpdate, gp.destination = "", pinStrategyLabel, false, ""
+	}()
+
+	get := func(strategy string, update bool) error {
+		gp.pinStrategy, gp.pinUpdate = strategy, update
+		return ExecuteGetValues()
+	}
+
+	if err := get(pinStrategyLabel, false); err != nil {
+		t.Fatal("Execute failed with: ", err)
+	}
+	l, exists, err := LoadLockfile(lockfile)
+	if err != nil || !exists || len(l.Entries) != 1 {
+		t.Fatalf("Expected lockfile with single entry got %v, %v", l, err)
+	}
+	testutil.AssertString(t, "Pinned version", "abc", l.Entries[0].Version)
+	testutil.AssertString(t, "Pinned label", "master", l.Entries[0].Label)
+	if !strings.HasPrefix(l.Entries[0].Checksums["values.yml"], checksumPrefix) {
+		t.Errorf("Expected values checksum got %v instead", l.Entries[0].Checksums)
+	}
+
+	// master moved to the next commit
+	s.AddProperties("app", "default", "", map[string]interface{}{"foo": "baz"})
+	s.SetVersion("", "def")
+
+	if err := get(pinStrategyVerify, false); !errors.Is(err, ErrPinMismatch) {
+		t.Errorf("Expected version mismatch got %v instead", err)
+	}
+
+	s.ResetRequests()
+	if err := get(pinStrategyLabel, false); err != nil {
+		t.Fatal("Execute failed with: ", err)
+	}
+	for _, r := range s.Requests() {
+		if !strings.Contains(r.Path, "abc") {
+			t.Errorf("Expected the pinned version used as label got request %s instead", r.Path)
+		}
+	}
+	raw, _ := os.ReadFile(destination)
+	testutil.AssertString(t, "Pinned content", "foo: bar\n", string(raw))
+
+	// the content of the pinned version changed, e.g. the server history was rewritten
+	s.AddProperties("app", "default", "abc", map[string]interface{}{"foo": "qux"})
+	if err := get(pinStrategyLabel, false); !errors.Is(err, ErrPinMismatch) {
+		t.Errorf("Expected checksum mismatch got %v instead", err)
+	}
+	raw, _ = os.ReadFile(destination)
+	testutil.AssertString(t, "Destination untouched", "foo: bar\n", string(raw))
+
+	if err := get(pinStrategyVerify, true); err != nil {
+		t.Fatal("Execute failed with: ", err)
+	}
+	l, _, _ = LoadLockfile(lockfile)
+	if len(l.Entries) != 1 {
+		t.Fatalf("Expected the entry updated got %d entries", len(l.Entries))
+	}
+	testutil.AssertString(t, "Updated version", "def", l.Entries[0].Version)
+	if err := get(pinStrategyVerify, false); err != nil {
+		t.Error("Execute failed with: ", err)
+	}
+}
+
+func TestExecuteGetFilesPin(t *testing.T) {
+	s := configtest.NewServer()
+	defer s.Close()
+	s.AddFile("", "a.conf", "a")
+	s.AddFile("", "b.conf", "b")
+
+	dir := t.TempDir()
+	gp.application = []string{"app"}
+	gp.profile = []string{"default"}
+	gp.label = "master"
+	gp.source = s.URL
+	gp.pin = filepath.Join(dir, "scccmd.lock")
+	gp.pinStrategy = pinStrategyLabel
+	defer func() { gp.pin = "" }()
+	gp.fileMappings = FileMappings{mappings: []FileMapping{
+		{source: "a.conf", destination: filepath.Join(dir, "a.conf")},
+		{source: "b.conf", destination: filepath.Join(dir, "b.conf")},
+	}}
+
+	if err := ExecuteGetFiles(); err != nil {
+		t.Fatal("Execute failed with: ", err)
+	}
+
+	// the server without version, only the checksums are verified
+	s.AddFile("", "a.conf", "changed a")
+	s.AddFile("", "b.conf", "changed b")
+	if err := ExecuteGetFiles(); !errors.Is(err, ErrPinMismatch) {
+		t.Errorf("Expected checksum mismatch got %v instead", err)
+	}
+	for _, name := range []string{"a", "b"} {
+		raw, _ := os.ReadFile(filepath.Join(dir, name+".conf"))
+		testutil.AssertString(t, "No file written", name, string(raw))
+	}
+}
+
+func TestNewPinnerInvalidStrategy(t *testing.T) {
+	if _, err := newPinner(pinOptions{lockfile: "scccmd.lock", strategy: "latest"}); err == nil {
+		t.Error("Expected invalid strategy error")
+	}
+	if p, err := newPinner(pinOptions{strategy: "latest"}); p != nil || err != nil {
+		t.Errorf("Expected no pinner without lockfile got %v, %v", p, err)
+	}
+}
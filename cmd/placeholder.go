@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wandera/scccmd/pkg/config"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// resolvePlaceholders expands '${...}' placeholders in the values of the config in given format.
+// Placeholders are looked up in the config itself first and in the environment variables second.
+func resolvePlaceholders(content string, format string) (string, error) {
+	if !strings.Contains(content, "${") {
+		return content, nil
+	}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return resolveJSON(content)
+	case "yaml", "yml":
+		return resolveYAML(content)
+	case "properties":
+		return resolveProperties(content)
+	default:
+		return content, nil
+	}
+}
+
+// resolveJSON resolves the values of the parsed node tree, so the key order and number literals are kept.
+func resolveJSON(content string) (string, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal([]byte(content), &doc); err != nil {
+		return "", err
+	}
+
+	properties := map[string]string{}
+	flattenNode("", &doc, properties)
+	if err := mapNode("", &doc, resolveFunc(properties)); err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	if err := writeJSONNode(&out, &doc, ""); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func resolveYAML(content string) (string, error) {
+	var data yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(content), &data); err != nil {
+		return "", err
+	}
+
+	resolved, err := resolveTree(data)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := yaml.Marshal(resolved)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(res), "\n"), nil
+}
+
+func resolveTree(data interface{}) (interface{}, error) {
+	properties := map[string]string{}
+	flattenTree("", data, properties)
+	return mapTree("", data, resolveFunc(properties))
+}
+
+// resolveFunc resolves the placeholders of the value using the properties and the environment variables.
+func resolveFunc(properties map[string]string) func(key string, value string) (string, error) {
+	r := config.NewResolver(config.PropertiesLookup(properties), config.EnvLookup)
+	return func(key string, value string) (string, error) {
+		resolved, err := r.Resolve(value)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+		return resolved, nil
+	}
+}
+
+// flattenTree collects the scalar values of the tree into properties with flattened keys e.g. 'a.b[0].c'.
+func flattenTree(prefix string, node interface{}, properties map[string]string) {
+	switch n := node.(type) {
+	case yaml.MapSlice:
+		for _, item := range n {
+			flattenTree(joinKey(prefix, fmt.Sprint(item.Key)), item.Value, properties)
+		}
+	case []interface{}:
+		for i, v := range n {
+			flattenTree(prefix+"["+strconv.Itoa(i)+"]", v, properties)
+		}
+	case nil:
+	default:
+		properties[prefix] = fmt.Sprint(n)
+	}
+}
+
+// mapTree returns the copy of the tree with all the string values replaced by the result of fn.
+func mapTree(prefix string, node interface{}, fn func(key string, value string) (string, error)) (interface{}, error) {
+	switch n := node.(type) {
+	case yaml.MapSlice:
+		res := make(yaml.MapSlice, 0, len(n))
+		for _, item := range n {
+			v, err := mapTree(joinKey(prefix, fmt.Sprint(item.Key)), item.Value, fn)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, yaml.MapItem{Key: item.Key, Value: v})
+		}
+		return res, nil
+	case []interface{}:
+		res := make([]interface{}, 0, len(n))
+		for i, item := range n {
+			v, err := mapTree(prefix+"["+strconv.Itoa(i)+"]", item, fn)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+		return res, nil
+	case string:
+		return fn(prefix, n)
+	default:
+		return node, nil
+	}
+}
+
+// flattenNode collects the scalar values of the node tree into properties with flattened keys e.g. 'a.b[0].c'.
+func flattenNode(prefix string, node *yamlv3.Node, properties map[string]string) {
+	switch node.Kind {
+	case yamlv3.DocumentNode:
+		for _, n := range node.Content {
+			flattenNode(prefix, n, properties)
+		}
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			flattenNode(joinKey(prefix, node.Content[i].Value), node.Content[i+1], properties)
+		}
+	case yamlv3.SequenceNode:
+		for i, n := range node.Content {
+			flattenNode(prefix+"["+strconv.Itoa(i)+"]", n, properties)
+		}
+	case yamlv3.ScalarNode:
+		if node.Tag != "!!null" {
+			properties[prefix] = node.Value
+		}
+	}
+}
+
+// mapNode replaces all the string values of the node tree in place by the result of fn.
+func mapNode(prefix string, node *yamlv3.Node, fn func(key string, value string) (string, error)) error {
+	switch node.Kind {
+	case yamlv3.DocumentNode:
+		for _, n := range node.Content {
+			if err := mapNode(prefix, n, fn); err != nil {
+				return err
+			}
+		}
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := mapNode(joinKey(prefix, node.Content[i].Value), node.Content[i+1], fn); err != nil {
+				return err
+			}
+		}
+	case yamlv3.SequenceNode:
+		for i, n := range node.Content {
+			if err := mapNode(prefix+"["+strconv.Itoa(i)+"]", n, fn); err != nil {
+				return err
+			}
+		}
+	case yamlv3.ScalarNode:
+		if node.Tag != "!!str" {
+			return nil
+		}
+		v, err := fn(prefix, node.Value)
+		if err != nil {
+			return err
+		}
+		node.Value = v
+	}
+	return nil
+}
+
+// resolveProperties resolves the values line by line, so the layout and comments of the properties are kept.
+func resolveProperties(content string) (string, error) {
+	lines := strings.Split(content, "\n")
+	properties := map[string]string{}
+	for _, line := range lines {
+		if key, _, value, ok := splitPropertyLine(line); ok {
+			properties[key] = unescapeProperty(value)
+		}
+	}
+	r := config.NewResolver(config.PropertiesLookup(properties), config.EnvLookup)
+
+	var out bytes.Buffer
+	for i, line := range lines {
+		if i > 0 {
+			out.WriteByte('\n')
+		}
+		key, head, value, ok := splitPropertyLine(line)
+		if !ok || !strings.Contains(value, "${") {
+			out.WriteString(line)
+			continue
+		}
+
+		resolved, err := r.Resolve(unescapeProperty(value))
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+		out.WriteString(head)
+		out.WriteString(escapeProperty(resolved))
+	}
+	return out.String(), nil
+}
+
+// splitPropertyLine splits the single properties line into the key, the line up to the value and the value.
+func splitPropertyLine(line string) (string, string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "!") {
+		return "", "", "", false
+	}
+
+	key := propertyKey(trimmed)
+	i := len(line) - len(strings.TrimLeft(line, " \t\f"))
+	escaped := false
+	for ; i < len(line); i++ {
+		c := line[i]
+		if escaped {
+			escaped = false
+			continue
+		}
+		if c == '\\' {
+			escaped = true
+			continue
+		}
+		if c == '=' || c == ':' || c == ' ' || c == '\t' {
+			break
+		}
+	}
+
+	j := i
+	for j < len(line) && (line[j] == ' ' || line[j] == '\t') {
+		j++
+	}
+	if j < len(line) && (line[j] == '=' || line[j] == ':') {
+		j++
+	}
+	for j < len(line) && (line[j] == ' ' || line[j] == '\t') {
+		j++
+	}
+	return key, line[:j], line[j:], true
+}
+
+func unescapeProperty(value string) string {
+	return strings.NewReplacer(`\:`, ":", `\=`, "=", `\\`, `\`, `\ `, " ", `\#`, "#", `\!`, "!").Replace(value)
+}
+
+func escapeProperty(value string) string {
+	return strings.NewReplacer(`\`, `\\`).Replace(value)
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+)
+
+func TestResolvePlaceholders(t *testing.T) {
+	t.Setenv("SCCCMD_TEST_PASSWORD", "secret")
+
+	testParams := []struct {
+		format   string
+		content  string
+		expected string
+	}{
+		{
+			"yaml",
+			"server:\n  host: localhost\n  port: 8080\nurl: http://${server.host}:${server.port}/${context.path:api}\n" +
+				"password: ${scccmd.test.password}\nlist:\n- ${server.host}\n- ${list[0]}-2",
+			"server:\n  host: localhost\n  port: 8080\nurl: http://localhost:8080/api\npassword: secret\nlist:\n- localhost\n- localhost-2",
+		},
+		{
+			"json",
+			"{\"a\":{\"b\":[\"x\",\"${a.b[0]}y\"]},\"n\":1,\"ref\":\"${n}\"}",
+			"{\n  \"a\": {\n    \"b\": [\n      \"x\",\n      \"xy\"\n    ]\n  },\n  \"n\": 1,\n  \"ref\": \"1\"\n}",
+		},
+		{
+			"json",
+			"{\"z\":1.50,\"a\":{\"y\":\"${z}\",\"b\":null}}",
+			"{\n  \"z\": 1.50,\n  \"a\": {\n    \"y\": \"1.50\",\n    \"b\": null\n  }\n}",
+		},
+		{
+			"properties",
+			"# comment ${a}\nhost=localhost\nurl = http\\://${host}/${context.path:api}\nplain=http\\://host",
+			"# comment ${a}\nhost=localhost\nurl = http://localhost/api\nplain=http\\://host",
+		},
+		{
+			"yaml",
+			"foo: bar",
+			"foo: bar",
+		},
+	}
+
+	for _, tp := range testParams {
+		got, err := resolvePlaceholders(tp.content, tp.format)
+		if err != nil {
+			t.Errorf("resolvePlaceholders failed with: %v", err)
+			continue
+		}
+		testutil.AssertString(t, "Incorrect resolved "+tp.format, tp.expected, got)
+	}
+}
+
+func TestResolvePlaceholdersErrors(t *testing.T) {
+	testParams := []struct {
+		format  string
+		content string
+		error   string
+	}{
+		{"yaml", "a: ${b}\nb: ${a}", "a: circular placeholder reference: b -> a -> b"},
+		{"json", "{\"a\":\"${missing}\"}", "a: unresolvable placeholder '${missing}'"},
+		{"properties", "a=${missing}", "a: unresolvable placeholder '${missing}'"},
+	}
+
+	for _, tp := range testParams {
+		_, err := resolvePlaceholders(tp.content, tp.format)
+		if err == nil {
+			t.Errorf("Expected error '%s'", tp.error)
+			continue
+		}
+		testutil.AssertString(t, "Incorrect error", tp.error, err.Error())
+	}
+}
+
+func TestExecuteGetValuesResolvePlaceholders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "host: localhost\nurl: http://${host}")
+	}))
+	defer ts.Close()
+
+	destination := filepath.Join(t.TempDir(), "config.yaml")
+	gp.application = []string{"app"}
+	gp.profile = []string{"default"}
+	gp.label = "master"
+	gp.source = ts.URL
+	gp.destination = destination
+	gp.format = "yaml"
+	gp.resolve = true
+	defer func() { gp.resolve = false }()
+
+	if err := ExecuteGetValues(); err != nil {
+		t.Fatal("Execute failed with: ", err)
+	}
+
+	raw, err := os.ReadFile(destination)
+	if err != nil {
+		t.Fatal("Expected to download file: ", err)
+	}
+	testutil.AssertString(t, "Incorrect content", "host: localhost\nurl: http://localhost", string(raw))
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	provenanceFileMode = 0o644
+	annotateTimeout    = 10 * time.Second
+	maxAnnotateBody    = 4 * 1024
+)
+
+// serviceAccountDir directory with the token and CA certificate of the pod service account.
+var serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// writeProvenance writes the provenance file and annotates the pod with it if the annotation key is set,
+// failed annotation is only logged to not block the pod start.
+func writeProvenance(path string, annotation string, provenance *Lockfile) error {
+	data, err := json.MarshalIndent(provenance, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(path, append(data, '\n'), fileOptions{mode: provenanceFileMode}); err != nil {
+		return err
+	}
+	log.Debug("Provenance written to: ", path)
+
+	if annotation == "" {
+		return nil
+	}
+	compact, err := json.Marshal(provenance)
+	if err != nil {
+		return err
+	}
+	if err := annotatePod(annotation, string(compact)); err != nil {
+		log.Warnf("Unable to annotate the pod with the config provenance: %v", err)
+		return nil
+	}
+	log.Debug("Pod annotated with the provenance: ", annotation)
+	return nil
+}
+
+// annotatePod sets the annotation of the pod the command runs in using the Kubernetes API and the pod service account.
+func annotatePod(key string, value string) error {
+	name, namespace := os.Getenv("POD_NAME"), os.Getenv("POD_NAMESPACE")
+	if name == "" || namespace == "" {
+		return errors.New("POD_NAME and POD_NAMESPACE environment variables are not set")
+	}
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return errors.New("not running in the Kubernetes cluster, KUBERNETES_SERVICE_HOST is not set")
+	}
+
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token")) // #nosec G304
+	if err != nil {
+		return err
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt")) // #nosec G304
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return errors.New("invalid service account CA certificate")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{key: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	uri := fmt.Sprintf("https://%s/api/v1/namespaces/%s/pods/%s", net.JoinHostPort(host, port), url.PathEscape(namespace), url.PathEscape(name))
+	req, err := http.NewRequest(http.MethodPatch, uri, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	c := &http.Client{
+		Timeout: annotateTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		},
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxAnnotateBody)) // #nosec G104
+		return fmt.Errorf("patch of pod %s/%s failed with %s: %s", namespace, name, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"github.com/wandera/scccmd/pkg/configtest"
+)
+
+func TestExecuteGetFilesProvenance(t *testing.T) {
+	s := configtest.NewServer()
+	defer s.Close()
+	s.AddFile("", "app.conf", "content")
+	s.SetVersion("", "abc")
+
+	var patch struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	var path, auth, contentType string
+	api := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth, contentType = r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &patch); err != nil {
+			t.Errorf("Invalid patch %s: %v", body, err)
+		}
+	}))
+	defer api.Close()
+
+	dir := t.TempDir()
+	serviceAccountDir = dir
+	defer func() { serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount" }()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: api.Certificate().Raw})
+	_ = os.WriteFile(filepath.Join(dir, "ca.crt"), ca, 0o600)
+	_ = os.WriteFile(filepath.Join(dir, "token"), []byte("secret\n"), 0o600)
+	host, port, _ := net.SplitHostPort(api.Listener.Addr().String())
+	t.Setenv("KUBERNETES_SERVICE_HOST", host)
+	t.Setenv("KUBERNETES_SERVICE_PORT", port)
+	t.Setenv("POD_NAME", "app-0")
+	t.Setenv("POD_NAMESPACE", "default")
+
+	provenance := filepath.Join(dir, "provenance.json")
+	gp.application = []string{"app"}
+	gp.profile = []string{"default"}
+	gp.label = "master"
+	gp.source = s.URL
+	gp.provenance = provenance
+	gp.provenanceAnnotation = "config.scccmd.github.com/provenance"
+	defer func() { gp.provenance, gp.provenanceAnnotation = "", "" }()
+	gp.fileMappings = FileMappings{mappings: []FileMapping{{source: "app.conf", destination: filepath.Join(dir, "app.conf")}}}
+
+	if err := ExecuteGetFiles(); err != nil {
+		t.Fatal("Execute failed with: ", err)
+	}
+
+	// the provenance file has the lockfile format
+	l, exists, err := LoadLockfile(provenance)
+	if err != nil || !exists || len(l.Entries) != 1 {
+		t.Fatalf("Expected provenance with single entry got %v, %v", l, err)
+	}
+	e := l.Entries[0]
+	testutil.AssertString(t, "Source", s.URL, e.Source)
+	testutil.AssertString(t, "Application", "app", e.Application)
+	testutil.AssertString(t, "Label", "master", e.Label)
+	testutil.AssertString(t, "Version", "abc", e.Version)
+	testutil.AssertString(t, "Checksum", "sha256:ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73", e.Checksums["app.conf"])
+	if e.Fetched.IsZero() {
+		t.Error("Expected fetch time recorded")
+	}
+
+	testutil.AssertString(t, "Patched pod", "/api/v1/namespaces/default/pods/app-0", path)
+	testutil.AssertString(t, "Authorization", "Bearer secret", auth)
+	testutil.AssertString(t, "Content type", "application/merge-patch+json", contentType)
+	var annotated Lockfile
+	if err := json.Unmarshal([]byte(patch.Metadata.Annotations["config.scccmd.github.com/provenance"]), &annotated); err != nil {
+		t.Fatalf("Invalid provenance annotation: %v", err)
+	}
+	if len(annotated.Entries) != 1 || annotated.Entries[0].Version != "abc" {
+		t.Errorf("Expected annotation with the provenance got %v instead", patch.Metadata.Annotations)
+	}
+}
+
+func TestAnnotatePodOutsideCluster(t *testing.T) {
+	t.Setenv("POD_NAME", "app-0")
+	t.Setenv("POD_NAMESPACE", "default")
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if err := annotatePod("key", "value"); err == nil {
+		t.Error("Expected error outside of the cluster")
+	}
+}
+
+func TestExecuteGetFilesProvenanceWithoutVersion(t *testing.T) {
+	s := configtest.NewServer()
+	defer s.Close()
+	s.AddFile("", "app.conf", "content")
+	s.Inject(configtest.Fault{Path: "/app/default/master", Status: http.StatusInternalServerError, Times: 1})
+
+	dir := t.TempDir()
+	provenance := filepath.Join(dir, "provenance.json")
+	gp.application = []string{"app"}
+	gp.profile = []string{"default"}
+	gp.label = "master"
+	gp.source = s.URL
+	gp.provenance = provenance
+	defer func() { gp.provenance = "" }()
+	gp.fileMappings = FileMappings{mappings: []FileMapping{{source: "app.conf", destination: filepath.Join(dir, "app.conf")}}}
+
+	// the version is not needed to get the config, only the pinning fails without it
+	if err := ExecuteGetFiles(); err != nil {
+		t.Fatal("Execute failed with: ", err)
+	}
+	l, exists, err := LoadLockfile(provenance)
+	if err != nil || !exists || len(l.Entries) != 1 {
+		t.Fatalf("Expected provenance with single entry got %v, %v", l, err)
+	}
+	testutil.AssertString(t, "Version", "", l.Entries[0].Version)
+	testutil.AssertString(t, "Checksum", "sha256:ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73", l.Entries[0].Checksums["app.conf"])
+
+	gp.pin = filepath.Join(dir, "config.lock")
+	defer func() { gp.pin = "" }()
+	s.Inject(configtest.Fault{Path: "/app/default/master", Status: http.StatusInternalServerError, Times: 1})
+	if err := ExecuteGetFiles(); err == nil {
+		t.Error("Expected pinning to fail without the version")
+	}
+}
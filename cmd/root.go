@@ -1,13 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/wandera/scccmd/pkg/client"
+	"github.com/wandera/scccmd/pkg/metrics"
 )
 
-var loglevel string
+const metricsPushTimeout = 10 * time.Second
+
+var (
+	loglevel       string
+	metricsGateway string
+	clientMetrics  = metrics.NewPrometheus("scccmd")
+)
 
 var rootCmd = &cobra.Command{
 	Use:               "scccmd",
@@ -29,6 +40,7 @@ Tool currently provides functionality to get (download) config file from server.
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&loglevel, "log-level", "info", fmt.Sprintf("command log level (options: %s)", log.AllLevels))
+	rootCmd.PersistentFlags().StringVar(&metricsGateway, "metrics-pushgateway", "", "address of the Prometheus Pushgateway the client metrics are pushed to when the command finishes")
 
 	rootCmd.AddCommand(getCmd)
 	rootCmd.AddCommand(genDocCmd)
@@ -37,9 +49,44 @@ func init() {
 	rootCmd.AddCommand(webhookCmd)
 	rootCmd.AddCommand(diffCmd)
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(injectCmd)
+}
+
+// newClient creates the client identifying the tool by the User-Agent header.
+func newClient(c client.Config) client.Client {
+	opts := []client.Option{client.WithUserAgent("scccmd/" + Version)}
+	if metricsGateway != "" {
+		opts = append(opts, client.WithMetrics(clientMetrics))
+	}
+	return client.NewClient(c, opts...)
+}
+
+// pushMetrics pushes the client metrics to the Pushgateway, failure is only logged to not mask the command result.
+func pushMetrics() {
+	if metricsGateway == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), metricsPushTimeout)
+	defer cancel()
+	if err := clientMetrics.Push(ctx, metricsGateway, "scccmd", metricsInstance()); err != nil {
+		log.Warnf("Unable to push metrics to %s: %v", metricsGateway, err)
+	}
+}
+
+// metricsInstance grouping key of the pushed metrics, so the commands running in different pods don't replace
+// the metrics of each other. The pod name is given by POD_NAME environment variable, the hostname is used otherwise.
+func metricsInstance() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	hostname, _ := os.Hostname() // #nosec G104
+	return hostname
 }
 
 // Execute run root command (main entrypoint).
 func Execute() error {
+	defer pushMetrics()
 	return rootCmd.Execute()
 }
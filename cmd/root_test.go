@@ -1,6 +1,16 @@
 package cmd
 
-import "testing"
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"github.com/wandera/scccmd/pkg/client"
+)
 
 func TestRootCommand(t *testing.T) {
 	err := rootCmd.Execute()
@@ -8,3 +18,37 @@ func TestRootCommand(t *testing.T) {
 		t.Error("Running root command should not throw exception", err)
 	}
 }
+
+func TestPushMetrics(t *testing.T) {
+	defer func() { metricsGateway = "" }()
+	t.Setenv("POD_NAME", "app-0")
+
+	var pushed string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/encrypt" {
+			_, _ = w.Write([]byte("cipher"))
+			return
+		}
+		testutil.AssertString(t, "Incorrect path", "/metrics/job/scccmd/instance/app-0", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		pushed = string(body)
+	}))
+	defer ts.Close()
+
+	metricsGateway = ts.URL
+	_, _ = newClient(client.Config{URI: ts.URL}).Encrypt("value")
+	pushMetrics()
+
+	if !strings.Contains(pushed, `scccmd_client_requests_total{endpoint="encrypt",result="ok"} 1`) {
+		t.Errorf("Expected encrypt request in pushed metrics got '%s' instead", pushed)
+	}
+}
+
+func TestMetricsInstance(t *testing.T) {
+	t.Setenv("POD_NAME", "")
+	hostname, _ := os.Hostname()
+	testutil.AssertString(t, "Incorrect instance", hostname, metricsInstance())
+
+	t.Setenv("POD_NAME", "app-0")
+	testutil.AssertString(t, "Incorrect instance", "app-0", metricsInstance())
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/wandera/scccmd/pkg/server"
+)
+
+var sp = struct {
+	listen       string
+	backend      string
+	dir          string
+	searchPaths  []string
+	defaultLabel string
+	encryptKey   string
+	encryptSalt  string
+}{}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the config from the local directory with the Spring Cloud Config Server API",
+	Long: `Serve the config from the local directory with the Spring Cloud Config Server API.
+The directory has the same layout as the config server 'native' profile, e.g. 'application.yml', 'app-prod.properties'.
+With the 'git' backend the committed content of the git repository is served, labels are branches, tags or commits.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ExecuteServe()
+	},
+}
+
+// ExecuteServe runs serve cmd.
+func ExecuteServe() error {
+	handler, err := newServer()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	s := &http.Server{
+		Addr:              sp.listen,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Minute,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.ListenAndServe()
+	}()
+	log.Infof("Serving config from %s on %s", sp.dir, sp.listen)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info("Shutdown signal received, exiting...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newServer creates the config server handler from the flags.
+func newServer() (*server.Server, error) {
+	var opts []server.Option
+	if sp.defaultLabel != "" {
+		opts = append(opts, server.WithDefaultLabel(sp.defaultLabel))
+	}
+	if sp.encryptKey != "" {
+		e, err := server.NewTextEncryptor(sp.encryptKey, sp.encryptSalt)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, server.WithEncryptor(e))
+	}
+
+	var backend server.Backend
+	switch sp.backend {
+	case "native":
+		backend = server.NewNativeBackend(sp.dir, sp.searchPaths...)
+	case "git":
+		backend = server.NewGitBackend(sp.dir, sp.searchPaths...)
+	default:
+		return nil, fmt.Errorf("unknown backend '%s'", sp.backend)
+	}
+	return server.New(backend, opts...), nil
+}
+
+func init() {
+	serveCmd.Flags().StringVarP(&sp.listen, "listen", "l", ":8888", "address the config server listens on")
+	serveCmd.Flags().StringVarP(&sp.backend, "backend", "b", "native", "backend serving the config (options: native, git)")
+	serveCmd.Flags().StringVarP(&sp.dir, "dir", "d", ".", "directory with the config files or the git repository")
+	serveCmd.Flags().StringSliceVar(&sp.searchPaths, "search-paths", nil, "comma separated subdirectories with the config files, might contain {application}, {profile} and {label} placeholders")
+	serveCmd.Flags().StringVar(&sp.defaultLabel, "default-label", "", "label used if the request does not specify one, defaults to "+server.DefaultLabel+" and to HEAD with the git backend")
+	serveCmd.Flags().StringVar(&sp.encryptKey, "encrypt-key", "", "symmetric key of the /encrypt and /decrypt endpoints and {cipher} values, encryption is disabled if empty")
+	serveCmd.Flags().StringVar(&sp.encryptSalt, "encrypt-salt", server.DefaultSalt, "hex encoded salt of the symmetric key")
+}
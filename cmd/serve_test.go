@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"github.com/wandera/scccmd/pkg/client"
+	"github.com/wandera/scccmd/pkg/server"
+)
+
+func TestNewServer(t *testing.T) {
+	defer func() { sp.backend, sp.dir, sp.encryptKey, sp.defaultLabel = "native", ".", "", "" }()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.yml"), []byte("key: value\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sp.backend = "native"
+	sp.dir = dir
+	sp.encryptKey = "secret"
+	sp.encryptSalt = server.DefaultSalt
+	sp.defaultLabel = "main"
+	handler, err := newServer()
+	if err != nil {
+		t.Fatal("newServer failed with: ", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	c := newClient(client.Config{URI: ts.URL, Applications: []string{"app"}, Profiles: []string{"default"}})
+	resp, err := c.FetchAsYAML()
+	if err != nil {
+		t.Fatal("FetchAsYAML failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect response", "key: value", resp)
+
+	env, err := c.FetchEnvironment()
+	if err != nil {
+		t.Fatal("FetchEnvironment failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect default label", "main", env.Label)
+
+	cipher, err := c.Encrypt("value")
+	if err != nil {
+		t.Fatal("Encrypt failed with: ", err)
+	}
+	if plain, err := c.Decrypt(cipher); err != nil || plain != "value" {
+		t.Errorf("Expected decrypted value got '%s' %v instead", plain, err)
+	}
+
+	sp.encryptSalt = "not hex"
+	if _, err := newServer(); err == nil {
+		t.Error("Expected invalid salt to fail")
+	}
+	sp.encryptSalt = server.DefaultSalt
+
+	sp.backend = "svn"
+	if _, err := newServer(); err == nil {
+		t.Error("Expected unknown backend to fail")
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wandera/scccmd/pkg/client"
+	"github.com/wandera/scccmd/pkg/watch"
+)
+
+// watching true if the config should be kept up to date after the first get.
+func watching() bool {
+	return gp.watchListen != "" || gp.watchInterval > 0
+}
+
+// runWatched runs the get and, if watching, keeps running it on change notifications or periodically
+// until the process is interrupted.
+func runWatched(c client.Config, execute func() error) error {
+	if err := execute(); err != nil || !watching() {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	w := watch.NewWatcher(c, gp.watchInterval, func(ctx context.Context) error {
+		return execute()
+	})
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	listenErr := make(chan error, 1)
+	if gp.watchListen != "" {
+		server := &http.Server{
+			Addr:              gp.watchListen,
+			Handler:           w,
+			ReadHeaderTimeout: time.Minute,
+		}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				listenErr <- fmt.Errorf("change notification listener failed: %v", err)
+				cancel()
+			}
+		}()
+		defer server.Close() // nolint: errcheck
+		log.Info("Listening for change notifications on ", gp.watchListen)
+	}
+
+	err := w.Run(runCtx)
+	select {
+	case err := <-listenErr:
+		return err
+	default:
+	}
+	if ctx.Err() != nil {
+		log.Info("Shutdown signal received, exiting...")
+	}
+	return err
+}
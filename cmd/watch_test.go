@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+func TestRunWatched(t *testing.T) {
+	defer func() { gp.watchInterval = 0 }()
+
+	var count int32
+	execute := func() error {
+		if atomic.AddInt32(&count, 1) == 3 {
+			_ = syscall.Kill(os.Getpid(), syscall.SIGINT) // #nosec G104
+		}
+		return nil
+	}
+
+	gp.watchInterval = 0
+	if err := runWatched(client.Config{}, execute); err != nil {
+		t.Fatal("runWatched failed with: ", err)
+	}
+	if c := atomic.LoadInt32(&count); c != 1 {
+		t.Fatalf("Expected single execution without watching got %d instead", c)
+	}
+
+	gp.watchInterval = 10 * time.Millisecond
+	done := make(chan error)
+	go func() { done <- runWatched(client.Config{}, execute) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error("runWatched failed with: ", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected runWatched to stop on interrupt")
+	}
+	if c := atomic.LoadInt32(&count); c < 3 {
+		t.Errorf("Expected periodic executions got %d instead", c)
+	}
+}
+
+func TestRunWatchedError(t *testing.T) {
+	defer func() { gp.watchInterval = 0 }()
+	gp.watchInterval = time.Hour
+
+	expected := errors.New("unavailable")
+	if err := runWatched(client.Config{}, func() error { return expected }); !errors.Is(err, expected) {
+		t.Errorf("Expected first get error to be returned got %v instead", err)
+	}
+}
+
+func TestRunWatchedListenError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	defer func() { gp.watchListen = "" }()
+	gp.watchListen = l.Addr().String()
+
+	done := make(chan error)
+	go func() { done <- runWatched(client.Config{}, func() error { return nil }) }()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "change notification listener failed") {
+			t.Errorf("Expected listener error got %v instead", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected runWatched to fail when the address is in use")
+	}
+}
+
+func TestManifest_WatchConfig(t *testing.T) {
+	m := Manifest{Profile: "default", Applications: []ManifestEntry{
+		{Application: "app"},
+		{Application: "app,common", Profile: "prod"},
+	}}
+
+	c := m.watchConfig()
+	testutil.AssertString(t, "Incorrect applications", "app|common", strings.Join(c.Applications, "|"))
+	testutil.AssertString(t, "Incorrect profiles", "default|prod", strings.Join(c.Profiles, "|"))
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wandera/scccmd/pkg/inject"
+	"sigs.k8s.io/yaml"
+)
+
+var wtp = struct {
+	configFile string
+	filename   string
+	namespace  string
+}{}
+
+var webhookTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Dry-run the webhook admission of the pod manifest",
+	Long: `Dry-run the webhook admission of the pod manifest in-process, without the cluster.
+Prints the injection policy decision with its reason, the AdmissionReview response, the JSON patch and the patched pod.
+The manifest might be a Pod or a workload with the pod template, e.g. Deployment.`,
+	Example: `  scccmd webhook test -c config/config.yaml -f pod.yaml -n default`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ExecuteWebhookTest(os.Stdout)
+	},
+}
+
+// ExecuteWebhookTest runs webhook test cmd.
+func ExecuteWebhookTest(w io.Writer) error {
+	config, err := inject.LoadConfig(wtp.configFile)
+	if err != nil {
+		return err
+	}
+
+	var manifest []byte
+	if wtp.filename == stdinPlaceholder {
+		manifest, err = io.ReadAll(os.Stdin)
+	} else {
+		manifest, err = os.ReadFile(wtp.filename)
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := inject.DryRun(manifest, wtp.namespace, config)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	decision := "not required"
+	if res.Required {
+		decision = "required"
+	}
+	fmt.Fprintf(&out, "Injection %s: %s\n", decision, res.Reason)
+	if r := res.Response.Response; r != nil {
+		fmt.Fprintf(&out, "Allowed: %v\n", r.Allowed)
+		if r.Result != nil && r.Result.Message != "" {
+			fmt.Fprintf(&out, "Error: %s\n", r.Result.Message)
+		}
+	}
+
+	response, err := json.MarshalIndent(res.Response, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&out, "\n# AdmissionReview response\n%s\n", response)
+
+	if len(res.Patch) > 0 {
+		out.WriteString("\n# JSON patch\n")
+		if err := json.Indent(&out, res.Patch, "", "  "); err != nil {
+			return err
+		}
+		out.WriteString("\n")
+	}
+
+	pod, err := yaml.JSONToYAML(res.Pod)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&out, "\n# Patched pod\n%s", pod)
+
+	_, err = out.WriteTo(w)
+	return err
+}
+
+func init() {
+	webhookTestCmd.Flags().StringVarP(&wtp.configFile, "config-file", "c", "config/config.yaml", "the webhook configuration file")
+	webhookTestCmd.Flags().StringVarP(&wtp.filename, "filename", "f", stdinPlaceholder, "pod manifest to admit, - reads from stdin")
+	webhookTestCmd.Flags().StringVarP(&wtp.namespace, "namespace", "n", "default", "namespace of the pod, used if the manifest does not specify one")
+	webhookCmd.AddCommand(webhookTestCmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecuteWebhookTest(t *testing.T) {
+	dir := t.TempDir()
+	wtp.configFile = filepath.Join(dir, "config.yaml")
+	wtp.filename = filepath.Join(dir, "pod.yaml")
+	wtp.namespace = "default"
+	defer func() { wtp.filename = stdinPlaceholder }()
+
+	_ = os.WriteFile(wtp.configFile, []byte("policy: disabled\n"), 0o600)
+	_ = os.WriteFile(wtp.filename, []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+  annotations:
+    config.scccmd.github.com/inject: "true"
+    config.scccmd.github.com/destination: /config/app.yaml
+spec:
+  containers:
+  - name: app
+`), 0o600)
+
+	var out bytes.Buffer
+	if err := ExecuteWebhookTest(&out); err != nil {
+		t.Fatal("Execute failed with: ", err)
+	}
+
+	for _, expected := range []string{
+		`Injection required: annotation config.scccmd.github.com/inject="true" overrides the "disabled" policy`,
+		"Allowed: true",
+		"# AdmissionReview response",
+		"# JSON patch",
+		"# Patched pod",
+		"name: config-init",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected output to contain %q got:\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultFileMode   = "0644"
+	checksumExtension = ".sha256"
+	ownerSeparator    = ":"
+)
+
+// fileOptions options applied to written config files.
+type fileOptions struct {
+	mode     os.FileMode
+	owner    string
+	checksum bool
+}
+
+// parseFileMode parse octal file mode e.g. '0600'.
+func parseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("invalid file mode '%s', expected octal permissions e.g. '0644'", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// parseOwner parse owner in form of user[:group], both might be either names or numeric ids.
+func parseOwner(owner string) (int, int, error) {
+	name, group, hasGroup := strings.Cut(owner, ownerSeparator)
+
+	uid, err := strconv.Atoi(name)
+	if err != nil {
+		u, lerr := user.Lookup(name)
+		if lerr != nil {
+			return 0, 0, fmt.Errorf("invalid owner '%s': %v", owner, lerr)
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, fmt.Errorf("invalid owner '%s': %v", owner, err)
+		}
+	}
+
+	gid := -1
+	if hasGroup {
+		if gid, err = strconv.Atoi(group); err != nil {
+			g, lerr := user.LookupGroup(group)
+			if lerr != nil {
+				return 0, 0, fmt.Errorf("invalid owner '%s': %v", owner, lerr)
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return 0, 0, fmt.Errorf("invalid owner '%s': %v", owner, err)
+			}
+		}
+	}
+
+	return uid, gid, nil
+}
+
+// pendingFile file being written, content is written to the temporary file in the destination directory
+// and renamed to the destination on Commit, so the destination is either untouched or complete.
+type pendingFile struct {
+	tmp         *os.File
+	destination string
+	opts        fileOptions
+	hash        hash.Hash
+}
+
+// newPendingFile creates the temporary file for the destination, missing parent directories are created.
+func newPendingFile(destination string, opts fileOptions) (*pendingFile, error) {
+	dir, name := filepath.Split(destination)
+	if dir == "" {
+		dir = "."
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil { // #nosec G301
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &pendingFile{
+		tmp:         tmp,
+		destination: destination,
+		opts:        opts,
+		hash:        sha256.New(),
+	}, nil
+}
+
+// Write writes the content into the temporary file.
+func (p *pendingFile) Write(b []byte) (int, error) {
+	n, err := p.tmp.Write(b)
+	p.hash.Write(b[:n]) // #nosec G104
+	return n, err
+}
+
+// Commit applies mode and owner and renames the temporary file to the destination.
+func (p *pendingFile) Commit() error {
+	if err := p.finish(); err != nil {
+		p.Abort()
+		return err
+	}
+
+	if err := os.Rename(p.tmp.Name(), p.destination); err != nil {
+		p.Abort()
+		return err
+	}
+
+	if p.opts.checksum {
+		line := fmt.Sprintf("%s  %s\n", p.Checksum(), filepath.Base(p.destination))
+		opts := p.opts
+		opts.checksum = false
+		if err := writeFile(p.destination+checksumExtension, []byte(line), opts); err != nil {
+			return err
+		}
+		log.Debug("Checksum written to: ", p.destination+checksumExtension)
+	}
+
+	return nil
+}
+
+// Checksum hex encoded sha256 of the content written so far.
+func (p *pendingFile) Checksum() string {
+	return hex.EncodeToString(p.hash.Sum(nil))
+}
+
+// Abort removes the temporary file, destination stays untouched.
+func (p *pendingFile) Abort() {
+	_ = p.tmp.Close()           // #nosec G104
+	_ = os.Remove(p.tmp.Name()) // #nosec G104
+}
+
+func (p *pendingFile) finish() error {
+	if err := p.tmp.Sync(); err != nil {
+		return err
+	}
+	if err := p.tmp.Chmod(p.opts.mode); err != nil {
+		return err
+	}
+	if p.opts.owner != "" {
+		uid, gid, err := parseOwner(p.opts.owner)
+		if err != nil {
+			return err
+		}
+		if err := p.tmp.Chown(uid, gid); err != nil {
+			return err
+		}
+	}
+	return p.tmp.Close()
+}
+
+// writeFile atomically writes the content to the destination, missing parent directories are created.
+func writeFile(destination string, content []byte, opts fileOptions) error {
+	f, err := newPendingFile(destination, opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Abort()
+		return err
+	}
+
+	return f.Commit()
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	destination := filepath.Join(dir, "nested", "dir", "secret.yaml")
+
+	if err := writeFile(destination, []byte("foo"), fileOptions{mode: 0o600, checksum: true}); err != nil {
+		t.Fatal("writeFile failed with: ", err)
+	}
+
+	raw, err := os.ReadFile(destination)
+	if err != nil {
+		t.Fatal("Expected file to be written: ", err)
+	}
+	testutil.AssertString(t, "Content mismatch", "foo", string(raw))
+
+	info, err := os.Stat(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected mode 0600 got %o instead", info.Mode().Perm())
+	}
+
+	sum, err := os.ReadFile(destination + checksumExtension)
+	if err != nil {
+		t.Fatal("Expected checksum to be written: ", err)
+	}
+	testutil.AssertString(t, "Checksum mismatch", "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae  secret.yaml", string(sum))
+
+	entries, err := os.ReadDir(filepath.Dir(destination))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected no temporary files left, got %v", entries)
+	}
+}
+
+func TestWriteFileInvalidOwner(t *testing.T) {
+	dir := t.TempDir()
+	destination := filepath.Join(dir, "file")
+
+	if err := writeFile(destination, []byte("foo"), fileOptions{mode: 0o644, owner: "no-such-user-exists"}); err == nil {
+		t.Error("writeFile expected to fail")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no files to be left, got %v", entries)
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	testParams := []struct {
+		value string
+		mode  os.FileMode
+		valid bool
+	}{
+		{"0644", 0o644, true},
+		{"600", 0o600, true},
+		{"0999", 0, false},
+		{"1777777", 0, false},
+		{"rw-r--r--", 0, false},
+	}
+
+	for _, tp := range testParams {
+		mode, err := parseFileMode(tp.value)
+		if tp.valid && (err != nil || mode != tp.mode) {
+			t.Errorf("Mode '%s' expected to parse as %o got %o, %v instead", tp.value, tp.mode, mode, err)
+		}
+		if !tp.valid && err == nil {
+			t.Errorf("Mode '%s' expected to be invalid", tp.value)
+		}
+	}
+}
+
+func TestParseOwner(t *testing.T) {
+	uid, gid, err := parseOwner("1000:2000")
+	if err != nil || uid != 1000 || gid != 2000 {
+		t.Errorf("Expected 1000:2000 got %d:%d, %v instead", uid, gid, err)
+	}
+
+	uid, gid, err = parseOwner("1000")
+	if err != nil || uid != 1000 || gid != -1 {
+		t.Errorf("Expected 1000:-1 got %d:%d, %v instead", uid, gid, err)
+	}
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"os"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/wandera/scccmd/cmd"
 )
 
 func main() {
 	if err := cmd.Execute(); err != nil {
-		log.Fatal(err)
+		log.Error(err)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	stdjson "encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	cacheBodyExtension = ".body"
+	cacheMetaExtension = ".meta"
+)
+
+// cache on-disk cache of the config server responses.
+type cache struct {
+	dir      string
+	maxStale time.Duration
+}
+
+// cacheEntry metadata of the cached response.
+type cacheEntry struct {
+	Key          string    `json:"key"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Validated    time.Time `json:"validated"`
+}
+
+// cacheWriter writes the response body into the cache, the entry is not visible until committed.
+type cacheWriter struct {
+	*os.File
+	cache *cache
+	entry cacheEntry
+}
+
+// cacheKey key of the response of the config server, the servers sharing the cache directory do not collide.
+func cacheKey(uri string, accept string, path string) string {
+	sum := sha256.Sum256([]byte(uri + "\n" + accept + "\n" + path))
+	return hex.EncodeToString(sum[:])
+}
+
+func newCache(dir string, maxStale time.Duration) *cache {
+	return &cache{dir: dir, maxStale: maxStale}
+}
+
+// load returns the cached entry metadata, nil if there is no usable entry.
+func (c *cache) load(key string) *cacheEntry {
+	data, err := os.ReadFile(c.path(key, cacheMetaExtension))
+	if err != nil {
+		return nil
+	}
+
+	var entry cacheEntry
+	if err := stdjson.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return nil
+	}
+	if _, err := os.Stat(c.path(key, cacheBodyExtension)); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// fresh true if the entry is not older than the staleness limit.
+func (c *cache) fresh(entry *cacheEntry) bool {
+	return c.maxStale <= 0 || time.Since(entry.Validated) <= c.maxStale
+}
+
+// copyTo writes the cached body into the writer.
+func (c *cache) copyTo(key string, w io.Writer) (int64, error) {
+	f, err := os.Open(c.path(key, cacheBodyExtension))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close() // nolint: errcheck
+
+	return io.Copy(w, f)
+}
+
+// touch marks the entry as validated by the server now.
+func (c *cache) touch(entry *cacheEntry) error {
+	entry.Validated = time.Now()
+	return c.writeMeta(entry)
+}
+
+// create starts writing of the new entry.
+func (c *cache) create(key string, etag string, lastModified string) (*cacheWriter, error) {
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &cacheWriter{
+		File:  f,
+		cache: c,
+		entry: cacheEntry{Key: key, ETag: etag, LastModified: lastModified},
+	}, nil
+}
+
+func (c *cache) writeMeta(entry *cacheEntry) error {
+	data, err := stdjson.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()           // #nosec G104
+		_ = os.Remove(f.Name()) // #nosec G104
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name()) // #nosec G104
+		return err
+	}
+	return os.Rename(f.Name(), c.path(entry.Key, cacheMetaExtension))
+}
+
+func (c *cache) path(key string, extension string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+extension)
+}
+
+// commit makes the written entry visible.
+func (w *cacheWriter) commit() error {
+	if err := w.Close(); err != nil {
+		w.abort()
+		return err
+	}
+	// metadata of the previous entry must not be paired with the new body
+	_ = os.Remove(w.cache.path(w.entry.Key, cacheMetaExtension)) // #nosec G104
+	if err := os.Rename(w.Name(), w.cache.path(w.entry.Key, cacheBodyExtension)); err != nil {
+		w.abort()
+		return err
+	}
+	return w.cache.touch(&w.entry)
+}
+
+// abort discards the written entry.
+func (w *cacheWriter) abort() {
+	_ = w.Close()           // #nosec G104
+	_ = os.Remove(w.Name()) // #nosec G104
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wandera/scccmd/internal/testutil"
+)
+
+func TestClient_Cache(t *testing.T) {
+	var status int
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case status != http.StatusOK:
+			w.WriteHeader(status)
+		case r.Header.Get("If-None-Match") == `"v1"`:
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = fmt.Fprint(w, "content")
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClient(Config{
+		URI:          ts.URL,
+		Applications: []string{"service"},
+		Profiles:     []string{"profile"},
+		Label:        "master",
+		CacheDir:     t.TempDir(),
+	})
+
+	testParams := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"fresh fetch", http.StatusOK, false},
+		{"not modified", http.StatusOK, false},
+		{"server failure", http.StatusServiceUnavailable, false},
+		{"not found is not masked", http.StatusNotFound, true},
+	}
+
+	for _, tp := range testParams {
+		status = tp.status
+		cont, err := c.FetchFileE("File")
+		if tp.wantErr {
+			if err == nil {
+				t.Errorf("%s: FetchFile expected to fail", tp.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: FetchFile failed with: %v", tp.name, err)
+		}
+		testutil.AssertString(t, tp.name, "content", string(cont))
+	}
+
+	if requests != len(testParams) {
+		t.Errorf("Expected %d requests got %d instead", len(testParams), requests)
+	}
+}
+
+func TestClient_CacheOffline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "foo: bar")
+	}))
+
+	dir := t.TempDir()
+	config := Config{
+		URI:           ts.URL,
+		Applications:  []string{"service"},
+		Profiles:      []string{"profile"},
+		Label:         "master",
+		CacheDir:      dir,
+		CacheMaxStale: time.Hour,
+	}
+
+	if _, err := NewClient(config).FetchAsYAML(); err != nil {
+		t.Fatal("FetchAsYAML failed with: ", err)
+	}
+	ts.Close()
+
+	cont, err := NewClient(config).FetchAsYAML()
+	if err != nil {
+		t.Fatal("Expected cached copy to be used, got: ", err)
+	}
+	testutil.AssertString(t, "Content mismatch", "foo: bar", cont)
+
+	c := NewClient(config).(*client)
+	entry := c.cache.load(cacheKey(config.URI, "", c.formatValuesURI(yaml, "master")))
+	if entry == nil {
+		t.Fatal("Expected cache entry to exist")
+	}
+	entry.Validated = time.Now().Add(-2 * time.Hour)
+	if err := c.cache.writeMeta(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.FetchAsYAML(); err == nil {
+		t.Error("Expected stale cached copy not to be used")
+	}
+}
+
+func TestClient_CacheSharedDir(t *testing.T) {
+	dir := t.TempDir()
+	var configs []Config
+	for _, content := range []string{"server: a", "server: b"} {
+		content := content
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprint(w, content)
+		}))
+		defer ts.Close()
+
+		config := Config{
+			URI:          ts.URL,
+			Applications: []string{"service"},
+			Profiles:     []string{"profile"},
+			Label:        "master",
+			CacheDir:     dir,
+		}
+		if _, err := NewClient(config).FetchAsYAML(); err != nil {
+			t.Fatal("FetchAsYAML failed with: ", err)
+		}
+		ts.Close()
+		configs = append(configs, config)
+	}
+
+	for i, expected := range []string{"server: a", "server: b"} {
+		cont, err := NewClient(configs[i]).FetchAsYAML()
+		if err != nil {
+			t.Fatalf("Expected cached copy to be used, got: %v", err)
+		}
+		testutil.AssertString(t, "Content of server "+configs[i].URI, expected, cont)
+	}
+}
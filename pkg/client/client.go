@@ -1,11 +1,16 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-resty/resty/v2"
-	log "github.com/sirupsen/logrus"
 )
 
 // Extension format of downloaded config.
@@ -28,12 +33,19 @@ func ParseExtension(str string) (Extension, error) {
 }
 
 const (
-	configPathFmt     = "/%s/%s-%s.%s"
-	configFilePathFmt = "/%s/%s/%s/%s"
-	encryptPath       = "/encrypt"
-	decryptPath       = "/decrypt"
+	configPathFmt                 = "/%s/%s-%s.%s"
+	configFilePathFmt             = "/%s/%s/%s/%s"
+	configFileDefaultLabelPathFmt = "/%s/%s/%s?useDefaultLabel"
+	encryptPath                   = "/encrypt"
+	decryptPath                   = "/decrypt"
+
+	binaryContentType = "application/octet-stream"
+	maxErrorBodySize  = 64 * 1024
 )
 
+// ErrFileTooLarge returned when the fetched file exceeds Config.MaxFileSize.
+var ErrFileTooLarge = errors.New("file exceeds the maximum allowed size")
+
 const (
 	json       Extension = "json"
 	properties Extension = "properties"
@@ -48,11 +60,16 @@ type Client interface {
 
 	// FetchFile queries the remote configuration service and returns the resulting file
 	// it is possible to pass error handler function as second parameter
+	//
+	// Deprecated: use FetchFileE and match the error with errors.Is, e.g. ErrNotFound
 	FetchFile(source string, errorHandler func([]byte, error) []byte) []byte
 
 	// FetchFileE queries the remote configuration service and returns the resulting file
 	FetchFileE(source string) ([]byte, error)
 
+	// FetchFileTo queries the remote configuration service and streams the resulting file into the writer
+	FetchFileTo(ctx context.Context, source string, w io.Writer) (int64, error)
+
 	// FetchAs queries the remote configuration service and returns the result in specified format
 	FetchAs(extension Extension) (string, error)
 
@@ -65,6 +82,9 @@ type Client interface {
 	// FetchAsProperties queries the remote configuration service and returns the result as a Properties string
 	FetchAsProperties() (string, error)
 
+	// FetchEnvironment queries the remote configuration service and returns the Environment
+	FetchEnvironment() (*Environment, error)
+
 	// Encrypt encrypts the value server side and returns result
 	Encrypt(value string) (string, error)
 
@@ -74,33 +94,50 @@ type Client interface {
 
 // Config needed to fetch a remote configuration.
 type Config struct {
-	URI         string
-	Profile     string
-	Application string
-	Label       string
-}
+	URI string
 
-type client struct {
-	config *Config
-	*resty.Client
-}
+	// Applications names of the applications, the server merges their config, later applications take precedence
+	Applications []string
+
+	// Profiles active profiles, the server merges their config, later profiles take precedence
+	Profiles []string
+
+	// Label of the configuration, comma separated labels are tried in order until the config is found
+	Label string
 
-// HTTPError used for wrapping an exception returned from Client.
-type HTTPError struct {
-	*resty.Response
+	// Binary fetches files as 'application/octet-stream', the file is returned byte exact without placeholders resolution
+	Binary bool
+
+	// UseDefaultLabel fetches files from the default label of the server, Label is ignored
+	UseDefaultLabel bool
+
+	// MaxFileSize maximum size of the fetched file in bytes, 0 means unlimited
+	MaxFileSize int64
+
+	// CacheDir directory of the on-disk response cache, empty disables the cache
+	CacheDir string
+
+	// CacheMaxStale maximum age of the cached response used when the server is unavailable, 0 means unlimited
+	CacheMaxStale time.Duration
 }
 
-// Error is an implementation of error type interface method.
-func (e HTTPError) Error() string {
-	return fmt.Sprintf("unexpected response %d %v", e.StatusCode(), string(e.Body()))
+type client struct {
+	config  *Config
+	cache   *cache
+	logger  Logger
+	metrics Metrics
+	tracer  Tracer
+	*resty.Client
 }
 
-// NewClient creates instance of the Client.
-func NewClient(c Config) Client {
-	r := resty.New().
-		SetBaseURL(c.URI).
+// NewClient creates instance of the Client, options customize the transport, headers and logging.
+func NewClient(c Config, opts ...Option) Client {
+	o := newOptions(opts)
+	r := resty.New()
+	r.SetBaseURL(c.URI).
 		SetRetryCount(3).
-		SetLogger(log.StandardLogger()).
+		SetLogger(o.logger).
+		SetTransport(o.roundTripper(r.GetClient().Transport)).
 		SetRedirectPolicy(resty.NoRedirectPolicy()).
 		OnAfterResponse(func(client *resty.Client, response *resty.Response) error {
 			if response.StatusCode() >= 300 || response.StatusCode() < 200 {
@@ -109,10 +146,25 @@ func NewClient(c Config) Client {
 			return nil
 		})
 
-	return &client{
-		config: &c,
-		Client: r,
+	for key, values := range o.headers {
+		r.Header[key] = values
+	}
+
+	var ch *cache
+	if c.CacheDir != "" {
+		ch = newCache(c.CacheDir, c.CacheMaxStale)
 	}
+
+	cl := &client{
+		config:  &c,
+		cache:   ch,
+		logger:  o.logger,
+		metrics: o.metrics,
+		tracer:  o.tracer,
+		Client:  r,
+	}
+	r.AddRetryHook(cl.observeRetry)
+	return cl
 }
 
 // Config of the client.
@@ -122,23 +174,45 @@ func (c *client) Config() *Config {
 
 // FetchFileE queries the remote configuration service and returns the resulting file.
 func (c *client) FetchFileE(source string) ([]byte, error) {
-	resp, err := c.R().Get(c.formatFileURI(source))
-	if err != nil {
+	var buf bytes.Buffer
+	if _, err := c.FetchFileTo(context.Background(), source, &buf); err != nil {
 		return nil, err
 	}
-	return resp.Body(), nil
+	return buf.Bytes(), nil
+}
+
+// FetchFileTo queries the remote configuration service and streams the resulting file into the writer,
+// returns number of bytes written. The writer might have received partial content if an error is returned.
+func (c *client) FetchFileTo(ctx context.Context, source string, w io.Writer) (int64, error) {
+	accept := ""
+	if c.config.Binary {
+		accept = binaryContentType
+	}
+
+	return c.instrument(ctx, EndpointFile, func(ctx context.Context) (int64, error) {
+		if c.config.UseDefaultLabel {
+			return c.get(ctx, c.formatFileURI(source, ""), accept, c.config.MaxFileSize, w)
+		}
+
+		return c.getWithLabels(ctx, func(label string) string {
+			return c.formatFileURI(source, label)
+		}, accept, c.config.MaxFileSize, w)
+	})
 }
 
 // FetchFile queries the remote configuration service and returns the resulting file.
+//
+// Deprecated: use FetchFileE and match the error with errors.Is, e.g. ErrNotFound.
 func (c *client) FetchFile(source string, errorHandler func([]byte, error) []byte) []byte {
-	resp, err := c.R().Get(c.formatFileURI(source))
+	data, err := c.FetchFileE(source)
 	if err != nil {
-		if resp != nil {
-			return errorHandler(resp.Body(), err)
+		var httpErr HTTPError
+		if errors.As(err, &httpErr) {
+			return errorHandler(httpErr.Body(), err)
 		}
 		return nil
 	}
-	return resp.Body()
+	return data
 }
 
 // FetchAsProperties queries the remote configuration service and returns the result as a Properties string.
@@ -158,41 +232,155 @@ func (c *client) FetchAsYAML() (string, error) {
 
 // FetchAs queries the remote configuration service and returns the result in specified format.
 func (c *client) FetchAs(extension Extension) (string, error) {
-	resp, err := c.R().Get(c.formatValuesURI(extension))
+	var buf bytes.Buffer
+	_, err := c.instrument(context.Background(), EndpointValues, func(ctx context.Context) (int64, error) {
+		return c.getWithLabels(ctx, func(label string) string {
+			return c.formatValuesURI(extension, label)
+		}, "", 0, &buf)
+	})
 	if err != nil {
 		return "", err
 	}
-	return resp.String(), nil
+	return strings.TrimSpace(buf.String()), nil
 }
 
 // Encrypt encrypts the value server side and returns result.
 func (c *client) Encrypt(value string) (string, error) {
-	resp, err := c.R().
-		SetHeader("Content-Type", "text/plain").
-		SetBody(value).
-		Post(encryptPath)
-	if err != nil {
-		return "", err
-	}
-	return resp.String(), nil
+	return c.post(context.Background(), EndpointEncrypt, encryptPath, value)
 }
 
 // Decrypt decrypts the value server side and returns result.
 func (c *client) Decrypt(value string) (string, error) {
-	resp, err := c.R().
-		SetHeader("Content-Type", "text/plain").
-		SetBody(value).
-		Post(decryptPath)
+	resp, err := c.post(context.Background(), EndpointDecrypt, decryptPath, value)
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode() < http.StatusInternalServerError && !errors.Is(err, ErrUnauthorized) {
+		return "", kindError{ErrDecryptionFailed, err}
+	}
+	return resp, err
+}
+
+// post posts the plain text value to the path and returns the response.
+func (c *client) post(ctx context.Context, endpoint Endpoint, path string, value string) (string, error) {
+	var result string
+	_, err := c.instrument(ctx, endpoint, func(ctx context.Context) (int64, error) {
+		resp, err := c.R().
+			SetContext(ctx).
+			SetHeader("Content-Type", "text/plain").
+			SetBody(value).
+			Post(path)
+		if err != nil {
+			return 0, unavailable(ctx, err)
+		}
+		result = resp.String()
+		return resp.Size(), nil
+	})
+	return result, err
+}
+
+// get queries the path and streams the response body into the writer, limit > 0 is the maximum size of the body.
+// With the cache enabled the response is validated against the cached copy and the cached copy is used
+// if the server is not reachable or fails.
+func (c *client) get(ctx context.Context, path string, accept string, limit int64, w io.Writer) (int64, error) {
+	key := cacheKey(c.config.URI, accept, path)
+	req := c.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true)
+	if accept != "" {
+		req.SetHeader("Accept", accept)
+	}
+
+	var cached *cacheEntry
+	if c.cache != nil {
+		if cached = c.cache.load(key); cached != nil {
+			if cached.ETag != "" {
+				req.SetHeader("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.SetHeader("If-Modified-Since", cached.LastModified)
+			}
+		}
+	}
+
+	resp, err := req.Get(path)
 	if err != nil {
-		return "", err
+		return c.fallback(key, cached, unavailable(ctx, err), w)
+	}
+	body := resp.RawBody()
+	defer body.Close() // nolint: errcheck
+
+	if resp.StatusCode() == http.StatusNotModified && cached != nil {
+		c.logger.Debugf("Response for %s not modified, using cached copy", path)
+		if err := c.cache.touch(cached); err != nil {
+			c.logger.Warnf("Unable to update cache entry for %s: %v", path, err)
+		}
+		return c.cache.copyTo(key, w)
 	}
-	return resp.String(), nil
+
+	if !resp.IsSuccess() {
+		data, _ := io.ReadAll(io.LimitReader(body, maxErrorBodySize)) // #nosec G104
+		err := HTTPError{resp.SetBody(data)}
+		if resp.StatusCode() >= http.StatusInternalServerError {
+			return c.fallback(key, cached, err, w)
+		}
+		return 0, err
+	}
+
+	if limit > 0 && resp.RawResponse.ContentLength > limit {
+		return 0, fmt.Errorf("%w: %s has %d bytes, limit is %d", ErrFileTooLarge, path, resp.RawResponse.ContentLength, limit)
+	}
+
+	var cw *cacheWriter
+	dst := w
+	if c.cache != nil {
+		if cw, err = c.cache.create(key, resp.Header().Get("ETag"), resp.Header().Get("Last-Modified")); err != nil {
+			c.logger.Warnf("Unable to cache response for %s: %v", path, err)
+		} else {
+			dst = io.MultiWriter(w, cw)
+		}
+	}
+
+	var n int64
+	if limit > 0 {
+		n, err = io.Copy(dst, io.LimitReader(body, limit+1))
+		if err == nil && n > limit {
+			err = fmt.Errorf("%w: %s has more than %d bytes", ErrFileTooLarge, path, limit)
+		}
+	} else {
+		n, err = io.Copy(dst, body)
+	}
+
+	if cw != nil {
+		if err != nil {
+			cw.abort()
+		} else if cerr := cw.commit(); cerr != nil {
+			c.logger.Warnf("Unable to cache response for %s: %v", path, cerr)
+		}
+	}
+	return n, err
+}
+
+// fallback uses the cached copy of the response if the server is not available.
+func (c *client) fallback(key string, cached *cacheEntry, err error, w io.Writer) (int64, error) {
+	if cached == nil {
+		return 0, err
+	}
+	if !c.cache.fresh(cached) {
+		c.logger.Warnf("Config server unavailable (%v), cached copy from %s exceeds the staleness limit %s",
+			err, cached.Validated.Format(time.RFC3339), c.cache.maxStale)
+		return 0, err
+	}
+
+	c.logger.Warnf("Config server unavailable (%v), using cached copy from %s", err, cached.Validated.Format(time.RFC3339))
+	return c.cache.copyTo(key, w)
 }
 
-func (c *client) formatValuesURI(extension Extension) string {
-	return fmt.Sprintf(configPathFmt, c.config.Label, c.config.Application, c.config.Profile, extension)
+func (c *client) formatValuesURI(extension Extension, label string) string {
+	return fmt.Sprintf(configPathFmt, escapeLabel(label), escapeList(c.config.Applications), escapeList(c.config.Profiles), extension)
 }
 
-func (c *client) formatFileURI(source string) string {
-	return fmt.Sprintf(configFilePathFmt, c.config.Application, c.config.Profile, c.config.Label, source)
+func (c *client) formatFileURI(source string, label string) string {
+	if c.config.UseDefaultLabel {
+		return fmt.Sprintf(configFileDefaultLabelPathFmt, escapeList(c.config.Applications), escapeList(c.config.Profiles), escapePath(source))
+	}
+	return fmt.Sprintf(configFilePathFmt, escapeList(c.config.Applications), escapeList(c.config.Profiles), escapeLabel(label), escapePath(source))
 }
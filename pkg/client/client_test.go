@@ -2,9 +2,12 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/wandera/scccmd/internal/testutil"
@@ -24,15 +27,15 @@ func TestNewClient(t *testing.T) {
 	}
 
 	c := NewClient(Config{
-		URI:         tp.URI,
-		Application: tp.application,
-		Profile:     tp.profile,
-		Label:       tp.label,
+		URI:          tp.URI,
+		Applications: []string{tp.application},
+		Profiles:     []string{tp.profile},
+		Label:        tp.label,
 	})
 
 	testutil.AssertString(t, "Incorrect URI", tp.URI, c.Config().URI)
-	testutil.AssertString(t, "Incorrect Application", tp.application, c.Config().Application)
-	testutil.AssertString(t, "Incorrect Profile", tp.profile, c.Config().Profile)
+	testutil.AssertString(t, "Incorrect Application", tp.application, strings.Join(c.Config().Applications, ","))
+	testutil.AssertString(t, "Incorrect Profile", tp.profile, strings.Join(c.Config().Profiles, ","))
 	testutil.AssertString(t, "Incorrect Label", tp.label, c.Config().Label)
 }
 
@@ -60,10 +63,10 @@ func TestErrorResponse(t *testing.T) {
 	defer ts.Close()
 
 	_, err := NewClient(Config{
-		URI:         ts.URL,
-		Application: tp.application,
-		Profile:     tp.profile,
-		Label:       tp.label,
+		URI:          ts.URL,
+		Applications: []string{tp.application},
+		Profiles:     []string{tp.profile},
+		Label:        tp.label,
 	}).FetchFileE(tp.fileName)
 
 	if err == nil {
@@ -96,10 +99,10 @@ func TestRedirectResponse(t *testing.T) {
 	defer ts.Close()
 
 	_, err := NewClient(Config{
-		URI:         ts.URL,
-		Application: tp.application,
-		Profile:     tp.profile,
-		Label:       tp.label,
+		URI:          ts.URL,
+		Applications: []string{tp.application},
+		Profiles:     []string{tp.profile},
+		Label:        tp.label,
 	}).FetchFileE(tp.fileName)
 
 	if err == nil {
@@ -130,10 +133,10 @@ func Test503Response(t *testing.T) {
 	defer ts.Close()
 
 	_, err := NewClient(Config{
-		URI:         ts.URL,
-		Application: tp.application,
-		Profile:     tp.profile,
-		Label:       tp.label,
+		URI:          ts.URL,
+		Applications: []string{tp.application},
+		Profiles:     []string{tp.profile},
+		Label:        tp.label,
 	}).FetchFileE(tp.fileName)
 
 	if err == nil {
@@ -165,10 +168,10 @@ func TestClient_FetchFile(t *testing.T) {
 	defer ts.Close()
 
 	cont, err := NewClient(Config{
-		URI:         ts.URL,
-		Application: tp.application,
-		Profile:     tp.profile,
-		Label:       tp.label,
+		URI:          ts.URL,
+		Applications: []string{tp.application},
+		Profiles:     []string{tp.profile},
+		Label:        tp.label,
 	}).FetchFileE(tp.fileName)
 	if err != nil {
 		t.Error("FetchFile failed with: ", err)
@@ -201,10 +204,10 @@ func TestClient_FetchAsYAML(t *testing.T) {
 	defer ts.Close()
 
 	cont, err := NewClient(Config{
-		URI:         ts.URL,
-		Application: tp.application,
-		Profile:     tp.profile,
-		Label:       tp.label,
+		URI:          ts.URL,
+		Applications: []string{tp.application},
+		Profiles:     []string{tp.profile},
+		Label:        tp.label,
 	}).FetchAsYAML()
 	if err != nil {
 		t.Error("FetchFile failed with: ", err)
@@ -274,3 +277,107 @@ func TestClient_Decrypt(t *testing.T) {
 
 	testutil.AssertString(t, "Content mismatch", tp.testContent, cont)
 }
+
+func TestClient_FetchEnvironment(t *testing.T) {
+	tp := struct {
+		application string
+		profile     string
+		label       string
+		URI         string
+		testContent string
+	}{
+		"service",
+		"profile",
+		"master",
+		"/service/profile/master",
+		`{"name":"service","profiles":["profile"],"label":"master","version":"abc","propertySources":[{"name":"file:/repo/service.yml","source":{"foo":"bar"}}]}`,
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testutil.AssertString(t, "Incorrect URI call", tp.URI, r.RequestURI)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, tp.testContent)
+	}))
+	defer ts.Close()
+
+	env, err := NewClient(Config{
+		URI:          ts.URL,
+		Applications: []string{tp.application},
+		Profiles:     []string{tp.profile},
+		Label:        tp.label,
+	}).FetchEnvironment()
+	if err != nil {
+		t.Fatal("FetchEnvironment failed with: ", err)
+	}
+
+	testutil.AssertString(t, "Incorrect Name", tp.application, env.Name)
+	testutil.AssertString(t, "Incorrect Version", "abc", env.Version)
+	if len(env.PropertySources) != 1 || env.PropertySources[0].Source["foo"] != "bar" {
+		t.Errorf("Unexpected property sources %v", env.PropertySources)
+	}
+}
+
+func TestClient_FetchFileTo(t *testing.T) {
+	content := []byte{0x00, 0xff, 0x0a, 0x0d, 0x7f}
+
+	testParams := []struct {
+		config  Config
+		URI     string
+		accept  string
+		wantErr bool
+	}{
+		{Config{Applications: []string{"service"}, Profiles: []string{"profile"}, Label: "master"}, "/service/profile/master/keystore.jks", "", false},
+		{Config{Applications: []string{"service"}, Profiles: []string{"profile"}, Label: "master", Binary: true}, "/service/profile/master/keystore.jks", "application/octet-stream", false},
+		{Config{Applications: []string{"service"}, Profiles: []string{"profile"}, Label: "master", UseDefaultLabel: true}, "/service/profile/keystore.jks?useDefaultLabel", "", false},
+		{Config{Applications: []string{"service"}, Profiles: []string{"profile"}, Label: "master", MaxFileSize: 5}, "/service/profile/master/keystore.jks", "", false},
+		{Config{Applications: []string{"service"}, Profiles: []string{"profile"}, Label: "master", MaxFileSize: 4}, "/service/profile/master/keystore.jks", "", true},
+	}
+
+	for _, tp := range testParams {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			testutil.AssertString(t, "Incorrect URI call", tp.URI, r.RequestURI)
+			if tp.accept != "" {
+				testutil.AssertString(t, "Incorrect Accept header", tp.accept, r.Header.Get("Accept"))
+			}
+			_, _ = w.Write(content)
+		}))
+
+		tp.config.URI = ts.URL
+		var buf bytes.Buffer
+		n, err := NewClient(tp.config).FetchFileTo(context.Background(), "keystore.jks", &buf)
+		ts.Close()
+
+		if tp.wantErr {
+			if !errors.Is(err, ErrFileTooLarge) {
+				t.Errorf("Expected ErrFileTooLarge got %v instead", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error("FetchFileTo failed with: ", err)
+		}
+		if n != int64(len(content)) || !bytes.Equal(buf.Bytes(), content) {
+			t.Errorf("Content mismatch expected %v got %v instead", content, buf.Bytes())
+		}
+	}
+}
+
+func TestClient_FetchFileToError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprint(w, "not found")
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	_, err := NewClient(Config{URI: ts.URL}).FetchFileTo(context.Background(), "File", &buf)
+
+	var httpErr HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode() != http.StatusNotFound {
+		t.Fatalf("Expected HTTPError 404 got %v instead", err)
+	}
+	testutil.AssertString(t, "Incorrect error body", "not found", string(httpErr.Body()))
+	if buf.Len() != 0 {
+		t.Error("Expected nothing to be written on error")
+	}
+}
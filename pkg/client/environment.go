@@ -0,0 +1,47 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	stdjson "encoding/json"
+	"fmt"
+)
+
+const environmentPathFmt = "/%s/%s/%s"
+
+// Environment configuration of the application as returned by the config server.
+type Environment struct {
+	Name            string           `json:"name"`
+	Profiles        []string         `json:"profiles"`
+	Label           string           `json:"label,omitempty"`
+	Version         string           `json:"version,omitempty"`
+	State           string           `json:"state,omitempty"`
+	PropertySources []PropertySource `json:"propertySources"`
+}
+
+// PropertySource single source of the properties, e.g. one file in config repository.
+type PropertySource struct {
+	Name   string                 `json:"name"`
+	Source map[string]interface{} `json:"source"`
+}
+
+// FetchEnvironment queries the remote configuration service and returns the Environment.
+func (c *client) FetchEnvironment() (*Environment, error) {
+	var buf bytes.Buffer
+	_, err := c.instrument(context.Background(), EndpointEnvironment, func(ctx context.Context) (int64, error) {
+		return c.getWithLabels(ctx, c.formatEnvironmentURI, "application/json", 0, &buf)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var env Environment
+	if err := stdjson.Unmarshal(buf.Bytes(), &env); err != nil {
+		return nil, fmt.Errorf("unable to parse environment: %v", err)
+	}
+	return &env, nil
+}
+
+func (c *client) formatEnvironmentURI(label string) string {
+	return fmt.Sprintf(environmentPathFmt, escapeList(c.config.Applications), escapeList(c.config.Profiles), escapeLabel(label))
+}
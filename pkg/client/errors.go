@@ -0,0 +1,137 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Errors matched by errors.Is against the errors returned from Client.
+var (
+	// ErrNotFound the application, profile or file does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidLabel the label does not exist in the config repository, it matches ErrNotFound as well
+	ErrInvalidLabel = errors.New("invalid label")
+
+	// ErrUnauthorized the credentials are missing or not allowed to access the config
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrServerUnavailable the config server is not reachable or is temporarily unable to handle the request
+	ErrServerUnavailable = errors.New("config server unavailable")
+
+	// ErrDecryptionFailed the server is unable to decrypt the value
+	ErrDecryptionFailed = errors.New("decryption failed")
+)
+
+// noSuchLabel prefix of the Spring Cloud Config NoSuchLabelException message.
+const noSuchLabel = "no such label"
+
+// ErrorDetails Spring Boot error response body.
+type ErrorDetails struct {
+	Timestamp string `json:"-"`
+	Status    int    `json:"status"`
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	Path      string `json:"path"`
+}
+
+// HTTPError used for wrapping an exception returned from Client.
+type HTTPError struct {
+	*resty.Response
+}
+
+// Error is an implementation of error type interface method.
+func (e HTTPError) Error() string {
+	d := e.Details()
+	if d == nil {
+		return strings.TrimSpace(fmt.Sprintf("unexpected response %d %v", e.StatusCode(), string(e.Body())))
+	}
+
+	msg := fmt.Sprintf("unexpected response %d %s", e.StatusCode(), d.Error)
+	if d.Message != "" {
+		msg += ": " + d.Message
+	}
+	if d.Path != "" {
+		msg += " (path " + d.Path + ")"
+	}
+	return msg
+}
+
+// Is matches the error against ErrNotFound, ErrInvalidLabel, ErrUnauthorized and ErrServerUnavailable
+// based on the status code and the error details.
+func (e HTTPError) Is(target error) bool {
+	code := e.StatusCode()
+	switch target {
+	case ErrNotFound:
+		return code == http.StatusNotFound
+	case ErrInvalidLabel:
+		d := e.Details()
+		return code == http.StatusNotFound && d != nil && strings.HasPrefix(strings.ToLower(d.Message), noSuchLabel)
+	case ErrUnauthorized:
+		return code == http.StatusUnauthorized || code == http.StatusForbidden
+	case ErrServerUnavailable:
+		return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+	default:
+		return false
+	}
+}
+
+// Details parses the Spring Boot error response body, returns nil if the body is not the error JSON.
+func (e HTTPError) Details() *ErrorDetails {
+	body := bytes.TrimSpace(e.Body())
+	if len(body) == 0 || body[0] != '{' {
+		return nil
+	}
+
+	var d struct {
+		ErrorDetails
+		Timestamp stdjson.RawMessage `json:"timestamp"`
+	}
+	if err := stdjson.Unmarshal(body, &d); err != nil {
+		return nil
+	}
+	// timestamp is either ISO string or epoch millis depending on the Spring Boot version
+	if err := stdjson.Unmarshal(d.Timestamp, &d.ErrorDetails.Timestamp); err != nil {
+		d.ErrorDetails.Timestamp = string(d.Timestamp)
+	}
+
+	if d.Status == 0 && d.Error == "" && d.Message == "" {
+		return nil
+	}
+	return &d.ErrorDetails
+}
+
+// kindError wraps the error so it matches the kind by errors.Is, the original error is still available to errors.As.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e kindError) Error() string {
+	return fmt.Sprintf("%v: %v", e.kind, e.err)
+}
+
+func (e kindError) Unwrap() error {
+	return e.err
+}
+
+func (e kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// unavailable wraps the error of the failed request as ErrServerUnavailable, unless the request was canceled
+// or the server responded.
+func unavailable(ctx context.Context, err error) error {
+	var httpErr HTTPError
+	if ctx.Err() != nil || errors.As(err, &httpErr) {
+		return err
+	}
+	return kindError{ErrServerUnavailable, err}
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+)
+
+func TestHTTPError(t *testing.T) {
+	tests := []struct {
+		status    int
+		body      string
+		matches   []error
+		message   string
+		timestamp string
+	}{
+		{
+			status:    http.StatusNotFound,
+			body:      `{"timestamp":"2024-01-02T10:00:00.000+00:00","status":404,"error":"Not Found","message":"No such label: foo","path":"/app/default/foo"}`,
+			matches:   []error{ErrNotFound, ErrInvalidLabel},
+			message:   "unexpected response 404 Not Found: No such label: foo (path /app/default/foo)",
+			timestamp: "2024-01-02T10:00:00.000+00:00",
+		},
+		{
+			status:    http.StatusNotFound,
+			body:      `{"timestamp":1704189600000,"status":404,"error":"Not Found","path":"/app/default/master/file"}`,
+			matches:   []error{ErrNotFound},
+			message:   "unexpected response 404 Not Found (path /app/default/master/file)",
+			timestamp: "1704189600000",
+		},
+		{
+			status:  http.StatusUnauthorized,
+			body:    "denied",
+			matches: []error{ErrUnauthorized},
+			message: "unexpected response 401 denied",
+		},
+		{
+			status:  http.StatusForbidden,
+			matches: []error{ErrUnauthorized},
+			message: "unexpected response 403",
+		},
+		{
+			status:  http.StatusServiceUnavailable,
+			matches: []error{ErrServerUnavailable},
+			message: "unexpected response 503",
+		},
+		{
+			status:  http.StatusInternalServerError,
+			body:    `{"status":500,"error":"Internal Server Error"}`,
+			message: "unexpected response 500 Internal Server Error",
+		},
+	}
+
+	all := []error{ErrNotFound, ErrInvalidLabel, ErrUnauthorized, ErrServerUnavailable, ErrDecryptionFailed}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.status), func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+
+			_, err := NewClient(Config{URI: ts.URL}).FetchFileE("file")
+			wrapped := fmt.Errorf("wrapped: %w", err)
+			for _, target := range all {
+				expected := false
+				for _, m := range tt.matches {
+					expected = expected || m == target
+				}
+				if errors.Is(wrapped, target) != expected {
+					t.Errorf("Expected errors.Is(%v) to be %v", target, expected)
+				}
+			}
+			testutil.AssertString(t, "Incorrect message", tt.message, err.Error())
+
+			var httpErr HTTPError
+			if !errors.As(wrapped, &httpErr) {
+				t.Fatal("Expected HTTPError")
+			}
+			if d := httpErr.Details(); d != nil {
+				testutil.AssertString(t, "Incorrect timestamp", tt.timestamp, d.Timestamp)
+			}
+		})
+	}
+}
+
+func TestServerUnavailable(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	uri := ts.URL
+	ts.Close()
+
+	c := NewClient(Config{URI: uri})
+	c.(*client).SetRetryCount(0)
+
+	if _, err := c.FetchFileE("file"); !errors.Is(err, ErrServerUnavailable) {
+		t.Errorf("Expected ErrServerUnavailable got %v instead", err)
+	}
+	if _, err := c.Encrypt("value"); !errors.Is(err, ErrServerUnavailable) {
+		t.Errorf("Expected ErrServerUnavailable got %v instead", err)
+	}
+	if Result(unavailable(context.Background(), &net.OpError{Op: "dial", Err: errors.New("refused")})) != ResultNetwork {
+		t.Error("Expected network result of the unavailable error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.FetchFileTo(ctx, "file", io.Discard); errors.Is(err, ErrServerUnavailable) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected canceled error got %v instead", err)
+	}
+}
+
+func TestDecryptionFailed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"status":400,"error":"Bad Request","message":"Text not encrypted with this key","path":"/decrypt"}`))
+	}))
+	defer ts.Close()
+
+	_, err := NewClient(Config{URI: ts.URL}, WithHeader("Authorization", "Basic dXNlcjpwYXNz")).Decrypt("value")
+	if !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("Expected ErrDecryptionFailed got %v instead", err)
+	}
+	testutil.AssertString(t, "Incorrect message",
+		"decryption failed: unexpected response 400 Bad Request: Text not encrypted with this key (path /decrypt)", err.Error())
+
+	_, err = NewClient(Config{URI: ts.URL}).Decrypt("value")
+	if errors.Is(err, ErrDecryptionFailed) || !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized got %v instead", err)
+	}
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Endpoint type of the config server endpoint.
+type Endpoint string
+
+// Config server endpoint types.
+const (
+	EndpointValues      Endpoint = "values"
+	EndpointFile        Endpoint = "file"
+	EndpointEnvironment Endpoint = "environment"
+	EndpointEncrypt     Endpoint = "encrypt"
+	EndpointDecrypt     Endpoint = "decrypt"
+)
+
+// Result classes of the client operations.
+const (
+	ResultOK          = "ok"
+	ResultNotFound    = "not_found"
+	ResultClientError = "client_error"
+	ResultServerError = "server_error"
+	ResultNetwork     = "network"
+	ResultTooLarge    = "too_large"
+	ResultCanceled    = "canceled"
+	ResultOther       = "other"
+)
+
+// Metrics receives the measurements of the client operations, implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveRequest observes the finished operation, result is one of the Result* classes
+	// and bytes is the size of the received content
+	ObserveRequest(endpoint Endpoint, result string, duration time.Duration, bytes int64)
+
+	// ObserveRetry observes the retried request
+	ObserveRetry(endpoint Endpoint)
+}
+
+// Tracer starts spans around the client operations, modelled after the OpenTelemetry tracer,
+// so it might be implemented by a thin adapter.
+type Tracer interface {
+	// Start starts the span, the returned context is used for the requests of the operation
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span single traced operation.
+type Span interface {
+	// SetAttribute sets the attribute of the span
+	SetAttribute(key string, value interface{})
+
+	// RecordError records the error the operation failed with
+	RecordError(err error)
+
+	// End ends the span
+	End()
+}
+
+type endpointKey struct{}
+
+// WithMetrics sets the metrics receiving the measurements of the client operations.
+func WithMetrics(metrics Metrics) Option {
+	return func(o *options) {
+		o.metrics = metrics
+	}
+}
+
+// WithTracer sets the tracer starting the spans around the client operations.
+func WithTracer(tracer Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// Result classifies the result of the client operation into one of the Result* classes.
+func Result(err error) string {
+	if err == nil {
+		return ResultOK
+	}
+
+	var httpErr HTTPError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ResultCanceled
+	case errors.Is(err, ErrFileTooLarge):
+		return ResultTooLarge
+	case errors.As(err, &httpErr):
+		switch code := httpErr.StatusCode(); {
+		case code == http.StatusNotFound:
+			return ResultNotFound
+		case code >= http.StatusInternalServerError:
+			return ResultServerError
+		default:
+			return ResultClientError
+		}
+	case errors.As(err, &netErr):
+		return ResultNetwork
+	default:
+		return ResultOther
+	}
+}
+
+// instrument measures and traces the operation on the endpoint.
+func (c *client) instrument(ctx context.Context, endpoint Endpoint, operation func(ctx context.Context) (int64, error)) (int64, error) {
+	ctx = context.WithValue(ctx, endpointKey{}, endpoint)
+
+	var span Span
+	if c.tracer != nil {
+		ctx, span = c.tracer.Start(ctx, "scccmd.client."+string(endpoint))
+		span.SetAttribute("scccmd.endpoint", string(endpoint))
+		span.SetAttribute("scccmd.application", c.config.Applications)
+		span.SetAttribute("scccmd.profile", c.config.Profiles)
+		span.SetAttribute("scccmd.label", c.config.Label)
+	}
+
+	start := time.Now()
+	n, err := operation(ctx)
+	result := Result(err)
+
+	if c.metrics != nil {
+		c.metrics.ObserveRequest(endpoint, result, time.Since(start), n)
+	}
+	if span != nil {
+		span.SetAttribute("scccmd.result", result)
+		span.SetAttribute("scccmd.bytes", n)
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+	return n, err
+}
+
+// observeRetry retry hook reporting the retries to the metrics.
+func (c *client) observeRetry(resp *resty.Response, _ error) {
+	if c.metrics == nil || resp == nil || resp.Request == nil {
+		return
+	}
+	if endpoint, ok := resp.Request.Context().Value(endpointKey{}).(Endpoint); ok {
+		c.metrics.ObserveRetry(endpoint)
+	}
+}
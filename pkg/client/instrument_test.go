@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wandera/scccmd/internal/testutil"
+)
+
+type testMetrics struct {
+	mu       sync.Mutex
+	requests []string
+	retries  map[Endpoint]int
+}
+
+func (m *testMetrics) ObserveRequest(endpoint Endpoint, result string, duration time.Duration, bytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, fmt.Sprintf("%s %s %d", endpoint, result, bytes))
+}
+
+func (m *testMetrics) ObserveRetry(endpoint Endpoint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.retries == nil {
+		m.retries = map[Endpoint]int{}
+	}
+	m.retries[endpoint]++
+}
+
+type testSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)                      { s.err = err }
+func (s *testSpan) End()                                       { s.ended = true }
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &testSpan{name: name, attrs: map[string]interface{}{}}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func TestResult(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{nil, ResultOK},
+		{context.Canceled, ResultCanceled},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), ResultCanceled},
+		{fmt.Errorf("%w: limit", ErrFileTooLarge), ResultTooLarge},
+		{errors.New("unknown"), ResultOther},
+	}
+
+	for _, tt := range tests {
+		testutil.AssertString(t, fmt.Sprintf("Incorrect result of %v", tt.err), tt.expected, Result(tt.err))
+	}
+}
+
+func TestClient_Instrument(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/service/profile/master/File":
+			_, _ = w.Write([]byte("content"))
+		case "/encrypt":
+			_, _ = w.Write([]byte("cipher"))
+		case "/decrypt":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	metrics := &testMetrics{}
+	tracer := &testTracer{}
+	c := NewClient(Config{
+		URI:          ts.URL,
+		Applications: []string{"service"},
+		Profiles:     []string{"profile"},
+		Label:        "master",
+	}, WithMetrics(metrics), WithTracer(tracer))
+
+	_, _ = c.FetchFileE("File")
+	_, _ = c.FetchAsYAML()
+	_, _ = c.FetchEnvironment()
+	_, _ = c.Encrypt("value")
+	_, _ = c.Decrypt("value")
+
+	testutil.AssertString(t, "Incorrect requests",
+		"file ok 7|values not_found 0|environment not_found 0|encrypt ok 6|decrypt client_error 0",
+		strings.Join(metrics.requests, "|"))
+
+	var spans []string
+	for _, span := range tracer.spans {
+		if !span.ended {
+			t.Errorf("Span %s not ended", span.name)
+		}
+		spans = append(spans, fmt.Sprintf("%s %v %v", span.name, span.attrs["scccmd.result"], span.err != nil))
+	}
+	testutil.AssertString(t, "Incorrect spans",
+		"scccmd.client.file ok false|scccmd.client.values not_found true|scccmd.client.environment not_found true|"+
+			"scccmd.client.encrypt ok false|scccmd.client.decrypt client_error true",
+		strings.Join(spans, "|"))
+	testutil.AssertString(t, "Incorrect span application", "[service]", fmt.Sprint(tracer.spans[0].attrs["scccmd.application"]))
+}
+
+func TestClient_InstrumentRetries(t *testing.T) {
+	var calls int32
+	transport := RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return nil, errors.New("connection reset")
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("cipher")),
+			Request:    r,
+		}, nil
+	})
+
+	metrics := &testMetrics{}
+	resp, err := NewClient(Config{URI: "http://config"}, WithTransport(transport), WithMetrics(metrics)).Encrypt("value")
+	if err != nil {
+		t.Fatal("Encrypt failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect response", "cipher", resp)
+
+	var retries []string
+	for endpoint, count := range metrics.retries {
+		retries = append(retries, fmt.Sprintf("%s %d", endpoint, count))
+	}
+	sort.Strings(retries)
+	testutil.AssertString(t, "Incorrect retries", "encrypt 2", strings.Join(retries, "|"))
+	testutil.AssertString(t, "Incorrect requests", "encrypt ok 6", strings.Join(metrics.requests, "|"))
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Logger used by the Client, satisfied by the logrus logger.
+type Logger interface {
+	Errorf(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+	Debugf(format string, v ...interface{})
+}
+
+// Middleware wraps the transport of the Client, it might modify the request or inspect the response,
+// e.g. to add tracing headers.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts the function to http.RoundTripper.
+type RoundTripperFunc func(r *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(r).
+func (f RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// Option customizes the Client created by NewClient.
+type Option func(o *options)
+
+type options struct {
+	transport  http.RoundTripper
+	middleware []Middleware
+	headers    http.Header
+	proxy      *url.URL
+	logger     Logger
+	metrics    Metrics
+	tracer     Tracer
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		headers: http.Header{},
+		logger:  log.StandardLogger(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTransport sets the transport used to send the requests.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// WithMiddleware wraps the transport with the middleware, the first one is the outermost.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// WithHeader sets the header sent with every request.
+func WithHeader(key string, value string) Option {
+	return func(o *options) {
+		o.headers.Set(key, value)
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return WithHeader("User-Agent", userAgent)
+}
+
+// WithProxy routes the requests through the proxy, the proxy from environment variables is used by default.
+// The proxy is applied only if the transport is *http.Transport.
+func WithProxy(proxy *url.URL) Option {
+	return func(o *options) {
+		o.proxy = proxy
+	}
+}
+
+// WithLogger sets the logger, logrus standard logger is used by default.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// roundTripper builds the transport from the base transport, proxy and middleware.
+func (o *options) roundTripper(base http.RoundTripper) http.RoundTripper {
+	if o.transport != nil {
+		base = o.transport
+	}
+
+	if o.proxy != nil {
+		if t, ok := base.(*http.Transport); ok {
+			t = t.Clone()
+			t.Proxy = http.ProxyURL(o.proxy)
+			base = t
+		} else {
+			o.logger.Warnf("Proxy %s ignored, transport %T does not support proxy", o.proxy.Redacted(), base)
+		}
+	}
+
+	for i := len(o.middleware) - 1; i >= 0; i-- {
+		base = o.middleware[i](base)
+	}
+	return base
+}
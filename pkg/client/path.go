@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const (
+	listSeparator = ","
+
+	// labelSlash Spring Cloud Config encoding of '/' in labels, e.g. 'feature(_)foo' for 'feature/foo'.
+	labelSlash = "(_)"
+)
+
+// SplitList splits the comma separated list, blank items are omitted.
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, listSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Labels splits the comma separated Label into the list of labels tried in order.
+func (c *Config) Labels() []string {
+	if labels := SplitList(c.Label); len(labels) > 0 {
+		return labels
+	}
+	return []string{""}
+}
+
+// Validate checks that at least one application and profile is set and none of them is blank or contains a comma.
+func (c *Config) Validate() error {
+	if err := validateList("application", c.Applications); err != nil {
+		return err
+	}
+	return validateList("profile", c.Profiles)
+}
+
+func validateList(name string, items []string) error {
+	if len(items) == 0 {
+		return fmt.Errorf("at least one %s is required", name)
+	}
+	for _, item := range items {
+		if strings.TrimSpace(item) == "" {
+			return fmt.Errorf("%s must not be blank", name)
+		}
+		if strings.Contains(item, listSeparator) {
+			return fmt.Errorf("%s '%s' must not contain '%s'", name, item, listSeparator)
+		}
+	}
+	return nil
+}
+
+// escapeLabel encodes '/' as '(_)' and escapes the label to be used as a single path segment.
+func escapeLabel(label string) string {
+	segments := strings.Split(label, "/")
+	for i, s := range segments {
+		segments[i] = escapeSegment(s)
+	}
+	return strings.Join(segments, labelSlash)
+}
+
+// escapeSegment escapes the value to be used as a single path segment.
+func escapeSegment(value string) string {
+	return url.PathEscape(value)
+}
+
+// escapeList escapes every item and joins them by comma into a single path segment.
+func escapeList(items []string) string {
+	escaped := make([]string, len(items))
+	for i, item := range items {
+		escaped[i] = escapeSegment(item)
+	}
+	return strings.Join(escaped, listSeparator)
+}
+
+// escapePath escapes every segment of the path, keeping the '/' separators.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// getWithLabels queries the path built for every label in order, until the resource is found.
+func (c *client) getWithLabels(ctx context.Context, path func(label string) string, accept string, limit int64, w io.Writer) (int64, error) {
+	labels := c.config.Labels()
+	for i, label := range labels {
+		n, err := c.get(ctx, path(label), accept, limit, w)
+		if i == len(labels)-1 || !errors.Is(err, ErrNotFound) {
+			return n, err
+		}
+		c.logger.Debugf("Not found for label %s, trying label %s", label, labels[i+1])
+	}
+	return 0, nil
+}
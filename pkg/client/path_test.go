@@ -0,0 +1,158 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+)
+
+func TestEscapeLabel(t *testing.T) {
+	testParams := []struct {
+		label string
+		want  string
+	}{
+		{"master", "master"},
+		{"feature/foo", "feature(_)foo"},
+		{"feature/foo/bar", "feature(_)foo(_)bar"},
+		{"release 1.0", "release%201.0"},
+		{"a?b#c", "a%3Fb%23c"},
+	}
+
+	for _, tp := range testParams {
+		testutil.AssertString(t, "Incorrect escaped label", tp.want, escapeLabel(tp.label))
+	}
+}
+
+func TestConfig_Labels(t *testing.T) {
+	testParams := []struct {
+		label string
+		want  []string
+	}{
+		{"master", []string{"master"}},
+		{"feature/foo,main", []string{"feature/foo", "main"}},
+		{" feature/foo , main ,", []string{"feature/foo", "main"}},
+		{"", []string{""}},
+	}
+
+	for _, tp := range testParams {
+		c := Config{Label: tp.label}
+		testutil.AssertString(t, "Incorrect labels", strings.Join(tp.want, "|"), strings.Join(c.Labels(), "|"))
+	}
+}
+
+func TestClient_LabelFallback(t *testing.T) {
+	testParams := []struct {
+		label   string
+		found   string
+		calls   []string
+		wantErr bool
+	}{
+		{"feature/foo,main", "/feature(_)foo/service-profile.yml", []string{"/feature(_)foo/service-profile.yml"}, false},
+		{"feature/foo,main", "/main/service-profile.yml", []string{"/feature(_)foo/service-profile.yml", "/main/service-profile.yml"}, false},
+		{"feature/foo,main", "", []string{"/feature(_)foo/service-profile.yml", "/main/service-profile.yml"}, true},
+		{"feature/foo,main", "error", []string{"/feature(_)foo/service-profile.yml"}, true},
+	}
+
+	for _, tp := range testParams {
+		var calls []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, r.RequestURI)
+			switch tp.found {
+			case r.RequestURI:
+				_, _ = w.Write([]byte("key: value"))
+			case "error":
+				w.WriteHeader(http.StatusForbidden)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		_, err := NewClient(Config{
+			URI:          ts.URL,
+			Applications: []string{"service"},
+			Profiles:     []string{"profile"},
+			Label:        tp.label,
+		}).FetchAsYAML()
+		ts.Close()
+
+		if tp.wantErr != (err != nil) {
+			t.Errorf("Unexpected error %v", err)
+		}
+		testutil.AssertString(t, "Incorrect calls", strings.Join(tp.calls, " "), strings.Join(calls, " "))
+	}
+}
+
+func TestClient_FetchFileEscaping(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testutil.AssertString(t, "Incorrect URI call", "/service/profile/feature(_)foo/dir/my%20file.yml", r.RequestURI)
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	data, err := NewClient(Config{
+		URI:          ts.URL,
+		Applications: []string{"service"},
+		Profiles:     []string{"profile"},
+		Label:        "feature/foo",
+	}).FetchFileE("dir/my file.yml")
+	if err != nil {
+		t.Error("FetchFileE failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect content", "content", string(data))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	testParams := []struct {
+		applications []string
+		profiles     []string
+		wantErr      bool
+	}{
+		{[]string{"service"}, []string{"default"}, false},
+		{[]string{"service", "common"}, []string{"default", "cloud"}, false},
+		{nil, []string{"default"}, true},
+		{[]string{"service"}, nil, true},
+		{[]string{"service", " "}, []string{"default"}, true},
+		{[]string{"service"}, []string{"default,cloud"}, true},
+	}
+
+	for _, tp := range testParams {
+		c := Config{Applications: tp.applications, Profiles: tp.profiles}
+		if err := c.Validate(); tp.wantErr != (err != nil) {
+			t.Errorf("Unexpected validation result %v for applications %v and profiles %v", err, tp.applications, tp.profiles)
+		}
+	}
+}
+
+func TestClient_MultipleProfiles(t *testing.T) {
+	testParams := []struct {
+		applications []string
+		profiles     []string
+		URI          string
+	}{
+		{[]string{"service"}, []string{"default", "cloud"}, "/master/service-default,cloud.yml"},
+		{[]string{"service", "common"}, []string{"cloud", "default"}, "/master/service,common-cloud,default.yml"},
+		{[]string{"my service"}, []string{"eu/west"}, "/master/my%20service-eu%2Fwest.yml"},
+	}
+
+	for _, tp := range testParams {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			testutil.AssertString(t, "Incorrect URI call", tp.URI, r.RequestURI)
+			_, _ = w.Write([]byte("key: value"))
+		}))
+
+		_, err := NewClient(Config{
+			URI:          ts.URL,
+			Applications: tp.applications,
+			Profiles:     tp.profiles,
+			Label:        "master",
+		}).FetchAsYAML()
+		ts.Close()
+
+		if err != nil {
+			t.Error("FetchAsYAML failed with: ", err)
+		}
+	}
+}
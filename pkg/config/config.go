@@ -0,0 +1,371 @@
+// Package config decodes the Environment returned by the config server into Go structs.
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+const (
+	tagName        = "config"
+	defaultTagName = "default"
+	listSeparator  = ","
+)
+
+// Load fetches the Environment using the client and decodes it into the target.
+func Load(c client.Client, target interface{}) error {
+	return LoadPrefix(c, "", target)
+}
+
+// LoadPrefix fetches the Environment using the client and decodes the properties under the prefix into the target.
+func LoadPrefix(c client.Client, prefix string, target interface{}) error {
+	env, err := c.FetchEnvironment()
+	if err != nil {
+		return err
+	}
+	return DecodePrefix(env, prefix, target)
+}
+
+// Decode decodes the Environment into the target, which must be a non-nil pointer.
+//
+// Struct fields are bound to the property named by the 'config' tag, or by the field name if the tag is missing,
+// nested structs bind the nested properties. Property names are matched using relaxed binding, so 'max-retries',
+// 'max_retries' and 'maxRetries' are all bound to the field MaxRetries. The 'default' tag holds the value used
+// if the property is not defined. Lists are bound either from indexed properties 'a.b[0].c' or from
+// comma separated values. The first property source has the highest precedence, as in Spring, and the indexed
+// list it defines replaces the whole list of the other sources.
+func Decode(env *client.Environment, target interface{}) error {
+	return DecodePrefix(env, "", target)
+}
+
+// DecodePrefix decodes the properties of the Environment under the prefix into the target.
+func DecodePrefix(env *client.Environment, prefix string, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+
+	root := newNode("")
+	for i := len(env.PropertySources) - 1; i >= 0; i-- {
+		for key, value := range env.PropertySources[i].Source {
+			path, err := parseKey(key)
+			if err != nil {
+				return err
+			}
+			root.set(path, value, i+1)
+		}
+	}
+
+	n := root
+	if prefix != "" {
+		path, err := parseKey(prefix)
+		if err != nil {
+			return err
+		}
+		n = root.get(path)
+	}
+
+	return decode(n, prefix, v.Elem())
+}
+
+// node property tree built from the flat property names.
+type node struct {
+	name     string
+	value    interface{}
+	hasValue bool
+	children map[string]*node
+	order    []string
+	// source of the list items, the list of the higher precedence source replaces the whole list as in Spring
+	source int
+}
+
+func newNode(name string) *node {
+	return &node{name: name, children: map[string]*node{}}
+}
+
+// set sets the value of the property from the source, sources must be set from the lowest precedence.
+func (n *node) set(path []string, value interface{}, source int) {
+	if len(path) == 0 {
+		n.value = value
+		n.hasValue = true
+		return
+	}
+
+	if isIndex(path[0]) && n.source != source {
+		n.removeIndexes()
+		n.source = source
+	}
+
+	key := canonical(path[0])
+	child, ok := n.children[key]
+	if !ok {
+		child = newNode(path[0])
+		n.children[key] = child
+		n.order = append(n.order, key)
+	}
+	child.set(path[1:], value, source)
+}
+
+// removeIndexes removes the list items set by the lower precedence sources.
+func (n *node) removeIndexes() {
+	order := n.order[:0]
+	for _, key := range n.order {
+		if isIndex(key) {
+			delete(n.children, key)
+			continue
+		}
+		order = append(order, key)
+	}
+	n.order = order
+}
+
+func isIndex(name string) bool {
+	_, err := strconv.Atoi(name)
+	return err == nil
+}
+
+func (n *node) get(path []string) *node {
+	for _, name := range path {
+		if n == nil {
+			return nil
+		}
+		n = n.children[canonical(name)]
+	}
+	return n
+}
+
+func (n *node) child(name string) *node {
+	if n == nil {
+		return nil
+	}
+	return n.children[canonical(name)]
+}
+
+// parseKey splits the property name into path segments, indexes and bracketed map keys are separate segments,
+// e.g. 'a.b[0].c' is split into 'a', 'b', '0', 'c' and 'a[x.y]' into 'a', 'x.y'.
+func parseKey(key string) ([]string, error) {
+	var path []string
+	var segment strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; c {
+		case '.':
+			if segment.Len() > 0 {
+				path = append(path, segment.String())
+				segment.Reset()
+			}
+		case '[':
+			if segment.Len() > 0 {
+				path = append(path, segment.String())
+				segment.Reset()
+			}
+			end := strings.IndexByte(key[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid property name '%s': missing ']'", key)
+			}
+			path = append(path, key[i+1:i+end])
+			i += end
+		default:
+			segment.WriteByte(c)
+		}
+	}
+	if segment.Len() > 0 {
+		path = append(path, segment.String())
+	}
+	return path, nil
+}
+
+// canonical relaxed form of the name, lower case without dashes and underscores.
+func canonical(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+func decode(n *node, path string, v reflect.Value) error {
+	if n == nil {
+		// the properties are missing, the defaults of the nested fields still apply
+		switch {
+		case v.Kind() == reflect.Struct:
+			return decodeStruct(&node{}, path, v)
+		case v.Kind() == reflect.Ptr && indirect(v.Type()).Kind() == reflect.Struct:
+			if !v.IsNil() {
+				return decode(nil, path, v.Elem())
+			}
+			// the pointer stays nil unless there is a default
+			elem := reflect.New(v.Type().Elem())
+			if err := decode(nil, path, elem.Elem()); err != nil {
+				return err
+			}
+			if !elem.Elem().IsZero() {
+				v.Set(elem)
+			}
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decode(n, path, v.Elem())
+	}
+
+	if n.hasValue && len(n.children) == 0 {
+		if err := decodeValue(n.value, v); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return decodeStruct(n, path, v)
+	case reflect.Map:
+		return decodeMap(n, path, v)
+	case reflect.Slice:
+		return decodeSlice(n, path, v)
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(n.interfaceValue()))
+		return nil
+	default:
+		return fmt.Errorf("%s: unable to bind nested properties to %s", path, v.Type())
+	}
+}
+
+func decodeStruct(n *node, path string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Tag.Get(tagName)
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && name == "" && indirect(f.Type).Kind() == reflect.Struct {
+			if err := decode(n, path, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		child := n.child(name)
+		if child == nil {
+			def, ok := f.Tag.Lookup(defaultTagName)
+			if !ok {
+				if err := decode(nil, join(path, name), v.Field(i)); err != nil {
+					return err
+				}
+				continue
+			}
+			child = &node{name: name, value: def, hasValue: true}
+		}
+
+		if err := decode(child, join(path, child.name), v.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeMap(n *node, path string, v reflect.Value) error {
+	t := v.Type()
+	if t.Key().Kind() != reflect.String {
+		return fmt.Errorf("%s: unsupported map key type %s", path, t.Key())
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+
+	for _, key := range n.order {
+		child := n.children[key]
+		elem := reflect.New(t.Elem()).Elem()
+		if existing := v.MapIndex(reflect.ValueOf(child.name).Convert(t.Key())); existing.IsValid() {
+			elem.Set(existing)
+		}
+		if err := decode(child, join(path, child.name), elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(child.name).Convert(t.Key()), elem)
+	}
+	return nil
+}
+
+func decodeSlice(n *node, path string, v reflect.Value) error {
+	indexes := make(map[int]*node, len(n.children))
+	size := 0
+	for _, child := range n.children {
+		i, err := strconv.Atoi(child.name)
+		if err != nil || i < 0 {
+			return fmt.Errorf("%s: invalid list index '%s'", path, child.name)
+		}
+		indexes[i] = child
+		if i >= size {
+			size = i + 1
+		}
+	}
+
+	s := reflect.MakeSlice(v.Type(), size, size)
+	for i := 0; i < size; i++ {
+		if err := decode(indexes[i], fmt.Sprintf("%s[%d]", path, i), s.Index(i)); err != nil {
+			return err
+		}
+	}
+	v.Set(s)
+	return nil
+}
+
+// interfaceValue converts the node into the plain value, map[string]interface{} or []interface{}.
+func (n *node) interfaceValue() interface{} {
+	if len(n.children) == 0 {
+		return n.value
+	}
+
+	list := make([]interface{}, len(n.children))
+	for _, child := range n.children {
+		i, err := strconv.Atoi(child.name)
+		if err != nil || i < 0 || i >= len(list) {
+			list = nil
+			break
+		}
+		list[i] = child.interfaceValue()
+	}
+	if list != nil {
+		return list
+	}
+
+	m := make(map[string]interface{}, len(n.children))
+	for _, child := range n.children {
+		m[child.name] = child.interfaceValue()
+	}
+	return m
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func join(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
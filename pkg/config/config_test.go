@@ -0,0 +1,308 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+type testServer struct {
+	Host    string        `config:"host"`
+	Port    int           `config:"port" default:"8080"`
+	Timeout time.Duration `default:"30s"`
+}
+
+type testUpstream struct {
+	Name    string
+	URL     string
+	Retries *int
+}
+
+type testConfig struct {
+	Server     testServer
+	MaxRetries int
+	Enabled    bool
+	Ratio      float64
+	Tags       []string
+	Upstreams  []testUpstream
+	Limits     map[string]Size
+	Labels     map[string]string
+	Ignored    string `config:"-"`
+	Raw        interface{}
+}
+
+func testEnvironment() *client.Environment {
+	return &client.Environment{
+		Name:     "app",
+		Profiles: []string{"default"},
+		PropertySources: []client.PropertySource{
+			{
+				Name: "app-default.yml",
+				Source: map[string]interface{}{
+					"server.host":            "override",
+					"max-retries":            float64(5),
+					"upstreams[1].name":      "second",
+					"upstreams[1].url":       "http://second",
+					"upstreams[0].name":      "first",
+					"upstreams[0].retries":   "2",
+					"limits.upload":          "10MB",
+					"limits.download":        "1GB",
+					"labels[team.name]":      "core",
+					"raw.a[0]":               "x",
+					"raw.a[1]":               "y",
+					"ignored":                "should not be set",
+					"server.timeout":         "PT1M",
+					"enabled":                true,
+					"ratio":                  0.5,
+					"tags":                   "a, b,c",
+					"application.unrelated":  "value",
+					"server.connect-timeout": "1s",
+				},
+			},
+			{
+				Name: "application.yml",
+				Source: map[string]interface{}{
+					"server.host":  "localhost",
+					"server.port":  float64(9090),
+					"max_retries":  float64(1),
+					"labels.owner": "ops",
+				},
+			},
+		},
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var c testConfig
+	if err := Decode(testEnvironment(), &c); err != nil {
+		t.Fatalf("Decode failed with: %v", err)
+	}
+
+	two := 2
+	expected := testConfig{
+		Server:     testServer{Host: "override", Port: 9090, Timeout: time.Minute},
+		MaxRetries: 5,
+		Enabled:    true,
+		Ratio:      0.5,
+		Tags:       []string{"a", "b", "c"},
+		Upstreams: []testUpstream{
+			{Name: "first", Retries: &two},
+			{Name: "second", URL: "http://second"},
+		},
+		Limits: map[string]Size{"upload": 10 * Megabyte, "download": Gigabyte},
+		Labels: map[string]string{"team.name": "core", "owner": "ops"},
+		Raw:    map[string]interface{}{"a": []interface{}{"x", "y"}},
+	}
+
+	if !reflect.DeepEqual(expected, c) {
+		t.Errorf("Decoded config mismatch\nexpected: %+v\ngot:      %+v", expected, c)
+	}
+}
+
+func TestDecodeDefaults(t *testing.T) {
+	var s testServer
+	if err := Decode(&client.Environment{}, &s); err != nil {
+		t.Fatalf("Decode failed with: %v", err)
+	}
+
+	expected := testServer{Port: 8080, Timeout: 30 * time.Second}
+	if s != expected {
+		t.Errorf("Expected %+v got %+v instead", expected, s)
+	}
+}
+
+func TestDecodeNestedDefaults(t *testing.T) {
+	env := &client.Environment{PropertySources: []client.PropertySource{{Name: "test", Source: map[string]interface{}{
+		"max-retries": float64(3),
+	}}}}
+
+	var c testConfig
+	if err := Decode(env, &c); err != nil {
+		t.Fatalf("Decode failed with: %v", err)
+	}
+	expected := testServer{Port: 8080, Timeout: 30 * time.Second}
+	if c.Server != expected || c.MaxRetries != 3 {
+		t.Errorf("Expected server %+v got %+v instead", expected, c)
+	}
+
+	var s testServer
+	if err := DecodePrefix(env, "server", &s); err != nil {
+		t.Fatalf("DecodePrefix failed with: %v", err)
+	}
+	if s != expected {
+		t.Errorf("Expected %+v got %+v instead", expected, s)
+	}
+}
+
+func TestDecodeNestedPointerDefaults(t *testing.T) {
+	var c struct {
+		Server   *testServer
+		Upstream *testUpstream
+	}
+	if err := Decode(&client.Environment{}, &c); err != nil {
+		t.Fatalf("Decode failed with: %v", err)
+	}
+	expected := testServer{Port: 8080, Timeout: 30 * time.Second}
+	if c.Server == nil || *c.Server != expected {
+		t.Errorf("Expected server %+v got %+v instead", expected, c.Server)
+	}
+	if c.Upstream != nil {
+		t.Errorf("Expected upstream without defaults nil got %+v instead", c.Upstream)
+	}
+}
+
+func TestDecodeListOverride(t *testing.T) {
+	env := &client.Environment{PropertySources: []client.PropertySource{
+		{Name: "app-prod.yml", Source: map[string]interface{}{
+			"upstreams[0].name": "prod",
+		}},
+		{Name: "application.yml", Source: map[string]interface{}{
+			"upstreams[0].name": "first",
+			"upstreams[0].url":  "http://first",
+			"upstreams[1].name": "second",
+			"upstreams[2].name": "third",
+			"tags[0]":           "a",
+			"tags[1]":           "b",
+		}},
+	}}
+
+	var c testConfig
+	if err := Decode(env, &c); err != nil {
+		t.Fatalf("Decode failed with: %v", err)
+	}
+	// the list of the highest precedence source replaces the whole list
+	expected := []testUpstream{{Name: "prod"}}
+	if !reflect.DeepEqual(expected, c.Upstreams) {
+		t.Errorf("Expected upstreams %+v got %+v instead", expected, c.Upstreams)
+	}
+	if !reflect.DeepEqual([]string{"a", "b"}, c.Tags) {
+		t.Errorf("Expected tags [a b] got %v instead", c.Tags)
+	}
+}
+
+func TestDecodePrefix(t *testing.T) {
+	var s testServer
+	if err := DecodePrefix(testEnvironment(), "server", &s); err != nil {
+		t.Fatalf("Decode failed with: %v", err)
+	}
+
+	expected := testServer{Host: "override", Port: 9090, Timeout: time.Minute}
+	if s != expected {
+		t.Errorf("Expected %+v got %+v instead", expected, s)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	testParams := []struct {
+		source map[string]interface{}
+		target interface{}
+		error  string
+	}{
+		{map[string]interface{}{"port": "http"}, &testServer{}, "port: invalid integer value 'http'"},
+		{map[string]interface{}{"timeout": "soon"}, &testServer{}, "timeout: invalid duration 'soon'"},
+		{map[string]interface{}{"upstreams[x].name": "a"}, &testConfig{}, "upstreams: invalid list index 'x'"},
+		{map[string]interface{}{"server": "a"}, &testConfig{}, "server: unable to bind value 'a' to config.testServer"},
+		{map[string]interface{}{"a[0": "a"}, &testConfig{}, "invalid property name 'a[0': missing ']'"},
+		{map[string]interface{}{}, testServer{}, "target must be a non-nil pointer, got config.testServer"},
+	}
+
+	for _, tp := range testParams {
+		env := &client.Environment{PropertySources: []client.PropertySource{{Name: "test", Source: tp.source}}}
+		err := Decode(env, tp.target)
+		if err == nil {
+			t.Errorf("Expected error '%s'", tp.error)
+			continue
+		}
+		testutil.AssertString(t, "Incorrect error", tp.error, err.Error())
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	testParams := []struct {
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"500", 500 * time.Millisecond, false},
+		{"10s", 10 * time.Second, false},
+		{"10S", 10 * time.Second, false},
+		{"2d", 48 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"PT15M", 15 * time.Minute, false},
+		{"P1DT1H", 25 * time.Hour, false},
+		{"-PT1S", -time.Second, false},
+		{"P", 0, true},
+		{"PT", 0, true},
+		{"10 apples", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tp := range testParams {
+		d, err := ParseDuration(tp.value)
+		if tp.wantErr != (err != nil) {
+			t.Errorf("Unexpected error %v for '%s'", err, tp.value)
+			continue
+		}
+		if d != tp.expected {
+			t.Errorf("Expected %s got %s instead for '%s'", tp.expected, d, tp.value)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	testParams := []struct {
+		value    string
+		expected Size
+		wantErr  bool
+	}{
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"10KB", 10 * Kilobyte, false},
+		{"10 mb", 10 * Megabyte, false},
+		{"1GB", Gigabyte, false},
+		{"2TB", 2 * Terabyte, false},
+		{"1.5GB", 0, true},
+		{"MB", 0, true},
+	}
+
+	for _, tp := range testParams {
+		s, err := ParseSize(tp.value)
+		if tp.wantErr != (err != nil) {
+			t.Errorf("Unexpected error %v for '%s'", err, tp.value)
+			continue
+		}
+		if s != tp.expected {
+			t.Errorf("Expected %s got %s instead for '%s'", tp.expected, s, tp.value)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testutil.AssertString(t, "Incorrect URI call", "/app/default/master", r.RequestURI)
+		_, _ = w.Write([]byte(`{"name":"app","profiles":["default"],"propertySources":[` +
+			`{"name":"app.yml","source":{"server.host":"example.com","server.port":8443}}]}`))
+	}))
+	defer ts.Close()
+
+	var s testServer
+	err := LoadPrefix(client.NewClient(client.Config{
+		URI:          ts.URL,
+		Applications: []string{"app"},
+		Profiles:     []string{"default"},
+		Label:        "master",
+	}), "server", &s)
+	if err != nil {
+		t.Fatalf("Load failed with: %v", err)
+	}
+
+	expected := testServer{Host: "example.com", Port: 8443, Timeout: 30 * time.Second}
+	if s != expected {
+		t.Errorf("Expected %+v got %+v instead", expected, s)
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+const (
+	placeholderPrefix    = "${"
+	placeholderSuffix    = '}'
+	placeholderSeparator = ':'
+	placeholderEscape    = '\\'
+)
+
+// ErrUnresolvablePlaceholder returned when the placeholder key is not defined and the placeholder has no default.
+var ErrUnresolvablePlaceholder = errors.New("unresolvable placeholder")
+
+// ErrCircularPlaceholder returned when the placeholder references itself, directly or through other placeholders.
+var ErrCircularPlaceholder = errors.New("circular placeholder reference")
+
+// Lookup returns the value of the key, false if the key is not defined.
+type Lookup func(key string) (string, bool)
+
+// Resolver expands '${key}' and '${key:default}' placeholders, the key is looked up using the lookups in order.
+// Values found are expanded as well, placeholders might be nested e.g. '${a:${b:default}}',
+// '\${' is kept as literal '${'.
+type Resolver struct {
+	lookups []Lookup
+}
+
+// NewResolver creates the Resolver using the lookups in order of precedence.
+func NewResolver(lookups ...Lookup) *Resolver {
+	return &Resolver{lookups: lookups}
+}
+
+// EnvironmentLookup looks the key up in the property sources, the first property source has the highest precedence.
+func EnvironmentLookup(env *client.Environment) Lookup {
+	return func(key string) (string, bool) {
+		for _, ps := range env.PropertySources {
+			if value, ok := ps.Source[key]; ok && value != nil {
+				return toString(value), true
+			}
+		}
+		return "", false
+	}
+}
+
+// PropertiesLookup looks the key up in the properties.
+func PropertiesLookup(properties map[string]string) Lookup {
+	return func(key string) (string, bool) {
+		value, ok := properties[key]
+		return value, ok
+	}
+}
+
+// EnvLookup looks the key up in the environment variables, both as it is and in the upper case form
+// with dots and dashes replaced by underscores, e.g. 'SERVER_PORT' for 'server.port'.
+func EnvLookup(key string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+	return os.LookupEnv(strings.ToUpper(strings.NewReplacer(".", "_", "-", "_", "[", "_", "]", "").Replace(key)))
+}
+
+// Resolve expands all the placeholders in the value.
+func (r *Resolver) Resolve(value string) (string, error) {
+	return r.resolve(value, nil)
+}
+
+// ResolveEnvironment expands the placeholders in all the string values of the Environment in place.
+func (r *Resolver) ResolveEnvironment(env *client.Environment) error {
+	// values are replaced once all of them are resolved, lookups might be reading the Environment
+	resolved := make([]map[string]string, len(env.PropertySources))
+	for i, ps := range env.PropertySources {
+		resolved[i] = map[string]string{}
+		for key, value := range ps.Source {
+			s, ok := value.(string)
+			if !ok || !strings.Contains(s, placeholderPrefix) {
+				continue
+			}
+			res, err := r.Resolve(s)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			resolved[i][key] = res
+		}
+	}
+
+	for i, ps := range env.PropertySources {
+		for key, value := range resolved[i] {
+			ps.Source[key] = value
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) resolve(value string, visiting []string) (string, error) {
+	if !strings.Contains(value, placeholderPrefix) {
+		return value, nil
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(value); {
+		if value[i] == placeholderEscape && strings.HasPrefix(value[i+1:], placeholderPrefix) {
+			out.WriteString(placeholderPrefix)
+			i += 1 + len(placeholderPrefix)
+			continue
+		}
+		if !strings.HasPrefix(value[i:], placeholderPrefix) {
+			out.WriteByte(value[i])
+			i++
+			continue
+		}
+
+		start := i + len(placeholderPrefix)
+		end := placeholderEnd(value, start)
+		if end < 0 {
+			// unterminated placeholder is kept as it is
+			out.WriteString(value[i:])
+			break
+		}
+
+		resolved, err := r.placeholder(value[start:end], visiting)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(resolved)
+		i = end + 1
+	}
+	return out.String(), nil
+}
+
+func (r *Resolver) placeholder(content string, visiting []string) (string, error) {
+	expr, def, hasDefault := cutDefault(content)
+
+	key, err := r.resolve(expr, visiting)
+	if err != nil {
+		return "", err
+	}
+
+	for _, k := range visiting {
+		if k == key {
+			return "", fmt.Errorf("%w: %s -> %s", ErrCircularPlaceholder, strings.Join(visiting, " -> "), key)
+		}
+	}
+
+	for _, lookup := range r.lookups {
+		if value, ok := lookup(key); ok {
+			return r.resolve(value, append(visiting[:len(visiting):len(visiting)], key))
+		}
+	}
+
+	if hasDefault {
+		return r.resolve(def, visiting)
+	}
+	return "", fmt.Errorf("%w '%s%s}'", ErrUnresolvablePlaceholder, placeholderPrefix, content)
+}
+
+// placeholderEnd index of the suffix closing the placeholder starting at start, -1 if there is none.
+func placeholderEnd(value string, start int) int {
+	depth := 0
+	for i := start; i < len(value); i++ {
+		switch {
+		case strings.HasPrefix(value[i:], placeholderPrefix):
+			depth++
+			i += len(placeholderPrefix) - 1
+		case value[i] == '{':
+			depth++
+		case value[i] == placeholderSuffix:
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// cutDefault splits the placeholder content on the first separator which is not part of a nested placeholder.
+func cutDefault(content string) (string, string, bool) {
+	depth := 0
+	for i := 0; i < len(content); i++ {
+		switch {
+		case strings.HasPrefix(content[i:], placeholderPrefix):
+			depth++
+			i += len(placeholderPrefix) - 1
+		case content[i] == placeholderSuffix:
+			depth--
+		case content[i] == placeholderSeparator && depth == 0:
+			return content[:i], content[i+1:], true
+		}
+	}
+	return content, "", false
+}
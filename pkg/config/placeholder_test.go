@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	t.Setenv("SCCCMD_TEST_HOST", "env-host")
+	t.Setenv("scccmd.test.exact", "exact")
+
+	r := NewResolver(PropertiesLookup(map[string]string{
+		"host":     "localhost",
+		"port":     "8080",
+		"url":      "http://${host}:${port}",
+		"name":     "host",
+		"empty":    "",
+		"indirect": "${url}/api",
+	}), EnvLookup)
+
+	testParams := []struct {
+		value    string
+		expected string
+	}{
+		{"plain value", "plain value"},
+		{"${host}", "localhost"},
+		{"${url}", "http://localhost:8080"},
+		{"${indirect}", "http://localhost:8080/api"},
+		{"${missing:default}", "default"},
+		{"${missing:}", ""},
+		{"${empty:default}", ""},
+		{"${missing:${host}}", "localhost"},
+		{"${missing:${other:nested}}", "nested"},
+		{"${${name}}", "localhost"},
+		{"${missing:http://x:1}", "http://x:1"},
+		{"${scccmd.test.host}", "env-host"},
+		{"${scccmd.test.exact}", "exact"},
+		{`\${host}`, "${host}"},
+		{"${host", "${host"},
+		{"{${port}}", "{8080}"},
+	}
+
+	for _, tp := range testParams {
+		got, err := r.Resolve(tp.value)
+		if err != nil {
+			t.Errorf("Resolve of '%s' failed with: %v", tp.value, err)
+			continue
+		}
+		testutil.AssertString(t, "Incorrect resolved value of "+tp.value, tp.expected, got)
+	}
+}
+
+func TestResolver_ResolveErrors(t *testing.T) {
+	r := NewResolver(PropertiesLookup(map[string]string{
+		"a":    "${b}",
+		"b":    "${c}",
+		"c":    "${a}",
+		"self": "x${self}",
+	}))
+
+	testParams := []struct {
+		value string
+		err   error
+		msg   string
+	}{
+		{"${a}", ErrCircularPlaceholder, "circular placeholder reference: a -> b -> c -> a"},
+		{"${self}", ErrCircularPlaceholder, "circular placeholder reference: self -> self"},
+		{"${missing}", ErrUnresolvablePlaceholder, "unresolvable placeholder '${missing}'"},
+	}
+
+	for _, tp := range testParams {
+		_, err := r.Resolve(tp.value)
+		if !errors.Is(err, tp.err) {
+			t.Errorf("Expected %v got %v instead", tp.err, err)
+			continue
+		}
+		testutil.AssertString(t, "Incorrect error", tp.msg, err.Error())
+	}
+}
+
+func TestResolver_ResolveEnvironment(t *testing.T) {
+	env := &client.Environment{
+		PropertySources: []client.PropertySource{
+			{Name: "app.yml", Source: map[string]interface{}{"host": "example.com", "literal": `\${host}`}},
+			{Name: "application.yml", Source: map[string]interface{}{"host": "localhost", "url": "http://${host}:${port}", "port": float64(80)}},
+		},
+	}
+
+	if err := NewResolver(EnvironmentLookup(env)).ResolveEnvironment(env); err != nil {
+		t.Fatalf("ResolveEnvironment failed with: %v", err)
+	}
+
+	var c struct {
+		URL     string
+		Literal string
+	}
+	if err := Decode(env, &c); err != nil {
+		t.Fatalf("Decode failed with: %v", err)
+	}
+	testutil.AssertString(t, "Incorrect url", "http://example.com:80", c.URL)
+	testutil.AssertString(t, "Incorrect literal", "${host}", c.Literal)
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Size data size in bytes, parsed from the Spring data size format e.g. '512B', '10KB' or '1GB',
+// units are powers of 1024 and the plain number is the number of bytes.
+type Size int64
+
+// Data size units.
+const (
+	Byte     Size = 1
+	Kilobyte      = 1024 * Byte
+	Megabyte      = 1024 * Kilobyte
+	Gigabyte      = 1024 * Megabyte
+	Terabyte      = 1024 * Gigabyte
+)
+
+var sizeUnits = []struct {
+	suffix string
+	size   Size
+}{
+	{"KB", Kilobyte},
+	{"MB", Megabyte},
+	{"GB", Gigabyte},
+	{"TB", Terabyte},
+	{"B", Byte},
+}
+
+// ParseSize parses the data size e.g. '10MB'.
+func ParseSize(s string) (Size, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+
+	unit := Byte
+	for _, u := range sizeUnits {
+		if number, ok := strings.CutSuffix(value, u.suffix); ok {
+			value = strings.TrimSpace(number)
+			unit = u.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid data size '%s'", s)
+	}
+	return Size(n) * unit, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler interface for Size.
+func (s *Size) UnmarshalText(text []byte) error {
+	size, err := ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*s = size
+	return nil
+}
+
+// String formats the size using the largest unit the size is divisible by.
+func (s Size) String() string {
+	for i := len(sizeUnits) - 2; i >= 0; i-- {
+		if u := sizeUnits[i]; s != 0 && s%u.size == 0 {
+			return fmt.Sprintf("%d%s", s/u.size, u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", int64(s))
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"encoding"
+	stdjson "encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+}
+
+// decodeValue binds the single property value to v.
+func decodeValue(raw interface{}, v reflect.Value) error {
+	if raw == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeValue(raw, v.Elem())
+	}
+
+	s := toString(raw)
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	if v.Type() == durationType {
+		d, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid bool value '%s'", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer value '%s'", s)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer value '%s'", s)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid float value '%s'", s)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		return decodeList(s, v)
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(raw))
+	default:
+		return fmt.Errorf("unable to bind value '%s' to %s", s, v.Type())
+	}
+	return nil
+}
+
+// decodeList binds the comma separated value to the slice.
+func decodeList(s string, v reflect.Value) error {
+	var items []string
+	if strings.TrimSpace(s) != "" {
+		items = strings.Split(s, listSeparator)
+	}
+
+	list := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := decodeValue(strings.TrimSpace(item), list.Index(i)); err != nil {
+			return err
+		}
+	}
+	v.Set(list)
+	return nil
+}
+
+func toString(raw interface{}) string {
+	switch value := raw.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case stdjson.Number:
+		return value.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// ParseDuration parses the duration in any of the formats supported by Spring, e.g. '10s', '500ms', '2d',
+// ISO-8601 'PT10M' or the plain number of milliseconds.
+func ParseDuration(s string) (time.Duration, error) {
+	value := strings.TrimSpace(s)
+	if value == "" {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+
+	if value[0] == 'P' || value[0] == 'p' || strings.HasPrefix(value, "-P") {
+		return parseISODuration(value)
+	}
+
+	i := strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '-' && r != '+' })
+	if i > 0 {
+		if unit, ok := durationUnits[strings.ToLower(value[i:])]; ok {
+			n, err := strconv.ParseInt(value[:i], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration '%s'", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+	return d, nil
+}
+
+// parseISODuration parses the ISO-8601 duration limited to days, hours, minutes and seconds, e.g. 'P2DT3H4M'.
+func parseISODuration(s string) (time.Duration, error) {
+	value := strings.ToUpper(s)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "-"), "P")
+
+	datePart, timePart, hasTime := strings.Cut(value, "T")
+	if (datePart == "" && timePart == "") || (hasTime && timePart == "") {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+
+	var d time.Duration
+	if datePart != "" {
+		days, ok := strings.CutSuffix(datePart, "D")
+		n, err := strconv.ParseInt(days, 10, 64)
+		if !ok || err != nil {
+			return 0, fmt.Errorf("invalid duration '%s'", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	}
+	if timePart != "" {
+		t, err := time.ParseDuration(strings.ToLower(timePart))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration '%s'", s)
+		}
+		d += t
+	}
+
+	if negative {
+		d = -d
+	}
+	return d, nil
+}
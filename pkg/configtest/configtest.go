@@ -0,0 +1,314 @@
+// Package configtest provides the in-memory fake Spring Cloud Config Server for testing the integrations
+// of the config client, with fixtures per application, profile and label, fault injection and request recording.
+package configtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing/fstest"
+	"time"
+
+	"github.com/wandera/scccmd/pkg/client"
+	"github.com/wandera/scccmd/pkg/server"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultEncryptKey symmetric key of the encryption endpoints unless WithEncryptKey is used.
+const DefaultEncryptKey = "configtest"
+
+// Request recorded request received by the Server.
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   string
+}
+
+// Fault injected into the requests received by the Server.
+type Fault struct {
+	// Path prefix of the requests the fault applies to, empty means all requests
+	Path string
+
+	// Latency delays the response
+	Latency time.Duration
+
+	// Status responds with the status code instead of serving the request, 0 serves the request
+	Status int
+
+	// Abort closes the connection without response, the client sees the network error
+	Abort bool
+
+	// Every applies the fault only to every n-th matching request, 0 or 1 means every request
+	Every int
+
+	// Times number of requests the fault applies to, 0 means unlimited
+	Times int
+}
+
+type fault struct {
+	Fault
+	matched int
+	applied int
+}
+
+// Option customizes the Server created by NewServer.
+type Option func(o *options)
+
+type options struct {
+	encryptKey   string
+	defaultLabel string
+}
+
+// WithEncryptKey sets the symmetric key of the encryption endpoints and '{cipher}' values.
+func WithEncryptKey(key string) Option {
+	return func(o *options) {
+		o.encryptKey = key
+	}
+}
+
+// WithDefaultLabel sets the label used if the request does not specify one, server.DefaultLabel is used by default.
+func WithDefaultLabel(label string) Option {
+	return func(o *options) {
+		o.defaultLabel = label
+	}
+}
+
+// Server fake Spring Cloud Config Server running on the local loopback interface.
+type Server struct {
+	// URL base URL of the server
+	URL string
+
+	server       *httptest.Server
+	encryptor    server.Encryptor
+	defaultLabel string
+
+	mu       sync.Mutex
+	labels   map[string]fstest.MapFS
+	versions map[string]string
+	faults   []*fault
+	requests []Request
+}
+
+// NewServer starts the Server, the caller should call Close when finished.
+func NewServer(opts ...Option) *Server {
+	o := options{encryptKey: DefaultEncryptKey, defaultLabel: server.DefaultLabel}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	encryptor, err := server.NewTextEncryptor(o.encryptKey, server.DefaultSalt)
+	if err != nil {
+		panic(fmt.Sprintf("configtest: %v", err))
+	}
+
+	s := &Server{
+		encryptor:    encryptor,
+		defaultLabel: o.defaultLabel,
+		labels:       map[string]fstest.MapFS{o.defaultLabel: {}},
+		versions:     map[string]string{},
+	}
+	handler := server.New(s, server.WithEncryptor(encryptor), server.WithDefaultLabel(o.defaultLabel))
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		if s.fail(w, r) {
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts the Server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Config returns the client config of the application and comma separated profiles served by the Server.
+func (s *Server) Config(application string, profile string) client.Config {
+	return client.Config{
+		URI:          s.URL,
+		Applications: client.SplitList(application),
+		Profiles:     client.SplitList(profile),
+		Label:        s.defaultLabel,
+	}
+}
+
+// AddFile adds the file of the config repository at the label, empty label means the default label,
+// e.g. 'application.yml', 'app-prod.properties' or 'nginx.conf'.
+func (s *Server) AddFile(label string, name string, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	label = s.label(label)
+	files, ok := s.labels[label]
+	if !ok {
+		files = fstest.MapFS{}
+		s.labels[label] = files
+	}
+	files[path.Clean(strings.TrimPrefix(name, "/"))] = &fstest.MapFile{Data: []byte(content), Mode: 0o444}
+}
+
+// AddProperties adds the properties of the application and profile at the label as '{application}-{profile}.yml',
+// empty profile means the properties of all the profiles and empty label means the default label.
+// Nested keys might be flattened e.g. 'server.port' or nested maps.
+func (s *Server) AddProperties(application string, profile string, label string, properties map[string]interface{}) {
+	data, err := yaml.Marshal(properties)
+	if err != nil {
+		panic(fmt.Sprintf("configtest: %v", err))
+	}
+
+	name := application
+	if profile != "" {
+		name += "-" + profile
+	}
+	s.AddFile(label, name+".yml", string(data))
+}
+
+// SetVersion sets the version of the label returned in the Environment, e.g. commit SHA.
+func (s *Server) SetVersion(label string, version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	label = s.label(label)
+	if _, ok := s.labels[label]; !ok {
+		s.labels[label] = fstest.MapFS{}
+	}
+	s.versions[label] = version
+}
+
+// Encrypt encrypts the value with the key of the Server, the result prefixed with '{cipher}' might be used in fixtures.
+func (s *Server) Encrypt(value string) string {
+	res, err := s.encryptor.Encrypt(value)
+	if err != nil {
+		panic(fmt.Sprintf("configtest: %v", err))
+	}
+	return res
+}
+
+// Inject injects the fault into the following requests, faults are evaluated in order and the first applicable wins.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &fault{Fault: f})
+}
+
+// ClearFaults removes all the injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// ResetRequests forgets the requests received so far.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+}
+
+// Snapshot implements server.Backend, labels without files and version do not exist.
+func (s *Server) Snapshot(_ context.Context, label string) (*server.Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, ok := s.labels[s.label(label)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", server.ErrNoSuchLabel, label)
+	}
+
+	// copy, so the fixtures might be changed while the request is served
+	snapshot := make(fstest.MapFS, len(files))
+	for name, file := range files {
+		snapshot[name] = file
+	}
+	return &server.Snapshot{
+		FS:       snapshot,
+		Location: "configtest:",
+		Version:  s.versions[s.label(label)],
+	}, nil
+}
+
+func (s *Server) label(label string) string {
+	if label == "" {
+		return s.defaultLabel
+	}
+	return label
+}
+
+func (s *Server) record(r *http.Request) {
+	body, _ := io.ReadAll(r.Body) // #nosec G104
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Header: r.Header.Clone(),
+		Body:   string(body),
+	})
+}
+
+// fail applies the first applicable fault, returns true if the request should not be served.
+func (s *Server) fail(w http.ResponseWriter, r *http.Request) bool {
+	f := s.fault(r)
+	if f == nil {
+		return false
+	}
+
+	if f.Latency > 0 {
+		select {
+		case <-time.After(f.Latency):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if f.Abort {
+		panic(http.ErrAbortHandler)
+	}
+	if f.Status != 0 {
+		w.WriteHeader(f.Status)
+		_, _ = fmt.Fprintf(w, `{"status":%d,"error":%q,"message":"injected fault","path":%q}`, f.Status, http.StatusText(f.Status), r.URL.Path) // #nosec G104
+		return true
+	}
+	return false
+}
+
+func (s *Server) fault(r *http.Request) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, f := range s.faults {
+		if !strings.HasPrefix(r.URL.Path, f.Path) || (f.Times > 0 && f.applied >= f.Times) {
+			continue
+		}
+		f.matched++
+		if f.Every > 1 && f.matched%f.Every != 0 {
+			continue
+		}
+		f.applied++
+		return &f.Fault
+	}
+	return nil
+}
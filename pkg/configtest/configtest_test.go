@@ -0,0 +1,132 @@
+package configtest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+func TestServer_Fixtures(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.AddProperties("application", "", "", map[string]interface{}{"log": "info"})
+	s.AddProperties("app", "prod", "", map[string]interface{}{
+		"server":   map[string]interface{}{"port": 8080},
+		"password": "{cipher}" + s.Encrypt("s3cr3t"),
+	})
+	s.AddProperties("app", "", "release", map[string]interface{}{"log": "debug"})
+	s.AddFile("", "nginx.conf", "listen ${server.port};")
+	s.SetVersion("release", "abc123")
+
+	c := client.NewClient(s.Config("app", "prod"))
+	yml, err := c.FetchAsYAML()
+	if err != nil {
+		t.Fatal("FetchAsYAML failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect values", "log: info\npassword: s3cr3t\nserver:\n  port: 8080", yml)
+
+	file, err := c.FetchFileE("nginx.conf")
+	if err != nil {
+		t.Fatal("FetchFileE failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect file", "listen 8080;", string(file))
+
+	config := s.Config("app", "default")
+	config.Label = "release"
+	env, err := client.NewClient(config).FetchEnvironment()
+	if err != nil {
+		t.Fatal("FetchEnvironment failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect version", "abc123", env.Version)
+	testutil.AssertString(t, "Incorrect source", "configtest:app.yml", env.PropertySources[0].Name)
+
+	config.Label = "missing"
+	if _, err := client.NewClient(config).FetchEnvironment(); !errors.Is(err, client.ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel got %v instead", err)
+	}
+	if _, err := client.NewClient(config).FetchFileE("nginx.conf"); !errors.Is(err, client.ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel of file got %v instead", err)
+	}
+	if _, err := c.FetchFileE("missing.conf"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound got %v instead", err)
+	}
+
+	plain, err := c.Decrypt(s.Encrypt("value"))
+	if err != nil || plain != "value" {
+		t.Errorf("Expected decrypted value got '%s' %v instead", plain, err)
+	}
+}
+
+func TestServer_Faults(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.AddProperties("app", "", "", map[string]interface{}{"key": "value"})
+	c := client.NewClient(s.Config("app", "default"))
+
+	s.Inject(Fault{Status: 503, Times: 2})
+	for i := 0; i < 2; i++ {
+		if _, err := c.FetchAsYAML(); !errors.Is(err, client.ErrServerUnavailable) {
+			t.Errorf("Expected ErrServerUnavailable got %v instead", err)
+		}
+	}
+	if _, err := c.FetchAsYAML(); err != nil {
+		t.Error("Expected fault to be applied twice only, got ", err)
+	}
+
+	s.ClearFaults()
+	s.Inject(Fault{Path: "/encrypt", Status: 401})
+	s.Inject(Fault{Status: 500, Every: 2})
+	var results []string
+	for i := 0; i < 4; i++ {
+		_, err := c.FetchAsYAML()
+		results = append(results, client.Result(err))
+	}
+	testutil.AssertString(t, "Incorrect flaky results", "ok,server_error,ok,server_error", strings.Join(results, ","))
+	if _, err := c.Encrypt("value"); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized got %v instead", err)
+	}
+
+	s.ClearFaults()
+	s.Inject(Fault{Abort: true})
+	if _, err := c.FetchEnvironment(); !errors.Is(err, client.ErrServerUnavailable) {
+		t.Errorf("Expected ErrServerUnavailable got %v instead", err)
+	}
+
+	s.ClearFaults()
+	s.Inject(Fault{Latency: 50 * time.Millisecond})
+	start := time.Now()
+	if _, err := c.FetchAsYAML(); err != nil {
+		t.Fatal("FetchAsYAML failed with: ", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected latency of at least 50ms got %s instead", elapsed)
+	}
+}
+
+func TestServer_Requests(t *testing.T) {
+	s := NewServer(WithDefaultLabel("main"), WithEncryptKey("other"))
+	defer s.Close()
+
+	c := client.NewClient(s.Config("app", "default"), client.WithHeader("X-Test", "yes"))
+	_, _ = c.FetchAsProperties()
+	_, _ = c.Encrypt("value")
+
+	requests := s.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests got %d instead", len(requests))
+	}
+	testutil.AssertString(t, "Incorrect path", "/main/app-default.properties", requests[0].Path)
+	testutil.AssertString(t, "Incorrect header", "yes", requests[0].Header.Get("X-Test"))
+	testutil.AssertString(t, "Incorrect method", "POST", requests[1].Method)
+	testutil.AssertString(t, "Incorrect body", "value", requests[1].Body)
+
+	s.ResetRequests()
+	if len(s.Requests()) != 0 {
+		t.Error("Expected requests to be reset")
+	}
+}
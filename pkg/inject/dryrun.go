@@ -0,0 +1,150 @@
+package inject
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+)
+
+// DryRunResult result of the pod admission by the webhook.
+type DryRunResult struct {
+	// Required whether the injection policy requires the injection
+	Required bool
+
+	// Reason human readable explanation of the policy decision
+	Reason string
+
+	// Response admission review response returned by the webhook
+	Response *v1.AdmissionReview
+
+	// Patch rfc6902 JSON patch of the pod, empty if the pod is not mutated
+	Patch []byte
+
+	// Pod patched pod as JSON, the same as the admitted pod if not mutated
+	Pod []byte
+}
+
+// DryRun admits the pod manifest by the webhook with the config in-process, the same way as the API server
+// would call the webhook on the pod creation. The manifest is YAML or JSON of a pod or a workload with the pod template,
+// the namespace is used if the manifest does not specify one.
+func DryRun(manifest []byte, namespace string, config *WebhookConfig) (*DryRunResult, error) {
+	pod, err := parsePod(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = namespace
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	review, err := json.Marshal(v1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{Kind: "AdmissionReview", APIVersion: "admission.k8s.io/v1"},
+		Request: &v1.AdmissionRequest{
+			UID:       types.UID("dry-run"),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "pods"},
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Operation: v1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+			DryRun:    ptr.To(true),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wh := &Webhook{config: config}
+	req := httptest.NewRequest(http.MethodPost, "/inject", bytes.NewReader(review))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	wh.serveInject(rec, req)
+	if rec.Code != http.StatusOK {
+		return nil, fmt.Errorf("webhook responded with %d: %s", rec.Code, bytes.TrimSpace(rec.Body.Bytes()))
+	}
+
+	result := &DryRunResult{Response: &v1.AdmissionReview{}, Pod: raw}
+	if err := json.Unmarshal(rec.Body.Bytes(), result.Response); err != nil {
+		return nil, fmt.Errorf("unable to decode webhook response: %v", err)
+	}
+	r := result.Response.Response
+	if r == nil {
+		return nil, fmt.Errorf("webhook responded without the admission response")
+	}
+	// the decision is the one the webhook made for the response
+	result.Required = r.AuditAnnotations[auditAnnotationRequired] == strconv.FormatBool(true)
+	result.Reason = r.AuditAnnotations[auditAnnotationReason]
+
+	if len(r.Patch) > 0 {
+		result.Patch = r.Patch
+		var doc interface{}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+		if doc, err = applyPatch(doc, r.Patch); err != nil {
+			return nil, fmt.Errorf("unable to apply the patch: %v", err)
+		}
+		if result.Pod, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// parsePod parses the pod manifest, pod template of the workloads is returned as the pod.
+func parsePod(manifest []byte) (*corev1.Pod, error) {
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal(manifest, &obj); err != nil {
+		return nil, fmt.Errorf("unable to parse manifest: %v", err)
+	}
+
+	kind, _ := obj["kind"].(string)
+	templatePath, ok := podTemplatePaths[kind]
+	if !ok {
+		return nil, fmt.Errorf("unsupported kind '%s', expected Pod or workload with the pod template", kind)
+	}
+	template := obj
+	for _, key := range templatePath {
+		if template, ok = template[key].(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("%s has no pod template", kind)
+		}
+	}
+
+	raw, err := json.Marshal(map[string]interface{}{"metadata": template["metadata"], "spec": template["spec"]})
+	if err != nil {
+		return nil, err
+	}
+	pod := &corev1.Pod{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"}}
+	if err := json.Unmarshal(raw, pod); err != nil {
+		return nil, err
+	}
+
+	// pod templates are named, namespaced and owned by the workload
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok && kind != "Pod" {
+		name, _ := metadata["name"].(string)
+		if pod.Name == "" {
+			pod.Name = name
+		}
+		if pod.Namespace == "" {
+			pod.Namespace, _ = metadata["namespace"].(string)
+		}
+		if len(pod.OwnerReferences) == 0 {
+			pod.OwnerReferences = podOwnerReferences(kind, name)
+		}
+	}
+	return pod, nil
+}
@@ -0,0 +1,112 @@
+package inject
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDryRun(t *testing.T) {
+	deployment := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: prod
+spec:
+  template:
+    metadata:
+      annotations:
+        config.scccmd.github.com/destination: /config/app.yaml
+    spec:
+      containers:
+      - name: app
+`
+	cases := []struct {
+		name      string
+		manifest  string
+		namespace string
+		required  bool
+		reason    string
+		patched   bool
+		message   string
+	}{
+		{
+			name:     "workload",
+			manifest: deployment,
+			required: true,
+			reason:   `no "config.scccmd.github.com/inject" annotation, the "enabled" policy applies`,
+			patched:  true,
+		},
+		{
+			name:     "opted out",
+			manifest: strings.Replace(deployment, "annotations:", "annotations:\n        config.scccmd.github.com/inject: \"off\"", 1),
+			reason:   `annotation config.scccmd.github.com/inject="off" overrides the "enabled" policy`,
+		},
+		{
+			name:      "ignored namespace",
+			manifest:  "kind: Pod\nmetadata:\n  name: app\nspec:\n  containers:\n  - name: app\n",
+			namespace: metav1.NamespaceSystem,
+			reason:    `namespace "kube-system" is ignored by "kube-system"`,
+		},
+		{
+			name:     "invalid annotations",
+			manifest: "kind: Pod\nmetadata:\n  name: app\nspec:\n  containers:\n  - name: app\n",
+			required: true,
+			reason:   `no "config.scccmd.github.com/inject" annotation, the "enabled" policy applies`,
+			message:  "one of 'config.scccmd.github.com/mapping' or 'config.scccmd.github.com/destination' annotations should be specified",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := DryRun([]byte(c.manifest), c.namespace, testConfig(t))
+			if err != nil {
+				t.Fatalf("DryRun() failed: %v", err)
+			}
+			if res.Required != c.required {
+				t.Errorf("Expected required %v got %v", c.required, res.Required)
+			}
+			testutil.AssertString(t, "Reason", c.reason, res.Reason)
+			testutil.AssertString(t, "UID", "dry-run", string(res.Response.Response.UID))
+			if c.message != "" {
+				testutil.AssertString(t, "Error", c.message, res.Response.Response.Result.Message)
+			}
+
+			var pod corev1.Pod
+			if err := json.Unmarshal(res.Pod, &pod); err != nil {
+				t.Fatal(err)
+			}
+			if patched := len(pod.Spec.InitContainers) > 0; patched != c.patched || (len(res.Patch) > 0) != c.patched {
+				t.Errorf("Expected patched %v got pod %s, patch %s", c.patched, res.Pod, res.Patch)
+			}
+			if c.patched {
+				testutil.AssertString(t, "Pod namespace", "prod", pod.Namespace)
+			}
+		})
+	}
+}
+
+func TestDryRunUnsupportedKind(t *testing.T) {
+	if _, err := DryRun([]byte("kind: Service\nmetadata:\n  name: app\n"), "", testConfig(t)); err == nil {
+		t.Error("Expected unsupported kind error")
+	}
+}
+
+func TestDryRunNamespaceScope(t *testing.T) {
+	config := testConfig(t)
+	config.Namespaces = []NamespaceConfig{{Namespace: "team-*", Policy: InjectionPolicyDisabled, Default: WebhookConfigDefaults{Source: "http://config-team"}}}
+	pod := "kind: Pod\nmetadata:\n  name: app\n  annotations:\n    config.scccmd.github.com/inject: \"true\"\n    config.scccmd.github.com/destination: config.yaml\nspec:\n  containers:\n  - name: app\n"
+
+	res, err := DryRun([]byte(pod), "team-a", config)
+	if err != nil {
+		t.Fatalf("DryRun() failed: %v", err)
+	}
+	testutil.AssertString(t, "Reason", `annotation config.scccmd.github.com/inject="true" overrides the "disabled" policy of namespace "team-*"`, res.Reason)
+	if !strings.Contains(string(res.Pod), "http://config-team") {
+		t.Errorf("Expected the namespace source used got %s", res.Pod)
+	}
+}
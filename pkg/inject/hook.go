@@ -9,7 +9,9 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
@@ -37,6 +39,11 @@ var (
 
 const (
 	watchDebounceDelay = 100 * time.Millisecond
+
+	// auditAnnotationRequired audit annotation whether the injection is required by the policy
+	auditAnnotationRequired = "injection-required"
+	// auditAnnotationReason audit annotation explaining the policy decision
+	auditAnnotationReason = "injection-reason"
 )
 
 // WebhookConfigDefaults configures default init container values.
@@ -49,6 +56,14 @@ type WebhookConfigDefaults struct {
 	Source        string `yaml:"source,omitempty"`
 }
 
+// NamespaceConfig injection policy and defaults of the namespaces matching the glob pattern, e.g. 'team-a-*'.
+// Empty policy and defaults are inherited from the WebhookConfig.
+type NamespaceConfig struct {
+	Namespace string                `yaml:"namespace"`
+	Policy    InjectionPolicy       `yaml:"policy,omitempty"`
+	Default   WebhookConfigDefaults `yaml:"default,omitempty"`
+}
+
 // InitContainerResourcesList resources for init container.
 type InitContainerResourcesList struct {
 	CPU    string `yaml:"cpu"`
@@ -61,6 +76,16 @@ type InitContainerResources struct {
 	Limits   InitContainerResourcesList `yaml:"limits"`
 }
 
+// InitContainerProvenance provenance of the config written by the init container.
+type InitContainerProvenance struct {
+	// File name of the provenance file in the config volume, empty disables the provenance, disabled by default
+	// as the init container images older than the provenance support fail on the unknown flag
+	File string `yaml:"file"`
+
+	// Annotate patches the provenance back into the pod annotation, the pod service account needs permission to patch pods
+	Annotate bool `yaml:"annotate,omitempty"`
+}
+
 type InitContainerSecurityContext struct {
 	AllowPrivilegeEscalation *bool `yaml:"allowPrivilegeEscalation,omitempty"`
 }
@@ -73,6 +98,16 @@ type WebhookConfig struct {
 	Default          WebhookConfigDefaults        `yaml:"default,omitempty"`
 	Resources        InitContainerResources       `yaml:"resources,omitempty"`
 	SecurityContext  InitContainerSecurityContext `yaml:"securityContext,omitempty"`
+	Provenance       InitContainerProvenance      `yaml:"provenance,omitempty"`
+
+	// IgnoredNamespaces glob patterns of the namespaces never injected, kube-system and kube-public if nil
+	IgnoredNamespaces []string `yaml:"ignored-namespaces,omitempty"`
+
+	// Namespaces policies and defaults per namespace, the first matching one applies
+	Namespaces []NamespaceConfig `yaml:"namespaces,omitempty"`
+
+	// Rules policies and defaults of the pods matching the selectors, the first matching one applies
+	Rules []InjectionRule `yaml:"rules,omitempty"`
 }
 
 // Webhook implements a mutating webhook for automatic config injection.
@@ -110,9 +145,10 @@ type WebhookParameters struct {
 func (w *WebhookConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	type rawWebhookConfig WebhookConfig
 	raw := rawWebhookConfig{
-		Policy:           InjectionPolicyEnabled,
-		ContainerImage:   "wanderadock/scccmd",
-		AnnotationPrefix: "config.scccmd.github.com/",
+		Policy:            InjectionPolicyEnabled,
+		IgnoredNamespaces: ignoredNamespaces,
+		ContainerImage:    "wanderadock/scccmd",
+		AnnotationPrefix:  "config.scccmd.github.com/",
 		Default: WebhookConfigDefaults{
 			ContainerName: "config-init",
 			VolumeMount:   "/config",
@@ -141,7 +177,7 @@ func (w *WebhookConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 // NewWebhook creates a new instance of a mutating webhook for automatic sidecar injection.
 func NewWebhook(p WebhookParameters) (*Webhook, error) {
-	config, err := loadConfig(p.ConfigFile)
+	config, err := LoadConfig(p.ConfigFile)
 	if err != nil {
 		return nil, err
 	}
@@ -208,7 +244,7 @@ func (wh *Webhook) Run(stop <-chan struct{}) {
 	for {
 		select {
 		case <-timerC:
-			config, err := loadConfig(wh.configFile)
+			config, err := LoadConfig(wh.configFile)
 			if err != nil {
 				log.Errorf("update error: %v", err)
 				break
@@ -299,7 +335,7 @@ func (wh *Webhook) Check() health.Health {
 	whHealth := health.NewHealth()
 	whHealth.Up()
 
-	_, err := loadConfig(wh.configFile)
+	_, err := LoadConfig(wh.configFile)
 	if err != nil {
 		whHealth.Down().AddInfo("config", fmt.Sprintf("error: %v", err))
 	} else {
@@ -317,9 +353,6 @@ func (wh *Webhook) Check() health.Health {
 }
 
 func (wh *Webhook) inject(ar *v1.AdmissionReview) *v1.AdmissionResponse {
-	statusKey := wh.config.AnnotationPrefix + "status"
-	injectKey := wh.config.AnnotationPrefix + "inject"
-
 	req := ar.Request
 	if req == nil {
 		log.Error("Could not parse request body")
@@ -335,29 +368,29 @@ func (wh *Webhook) inject(ar *v1.AdmissionReview) *v1.AdmissionResponse {
 		log.Errorf("Could not unmarshal raw object: %v", err)
 		return toAdmissionResponse(err)
 	}
+	// pods created by the controllers do not have the namespace set yet
+	if pod.Namespace == "" {
+		pod.Namespace = req.Namespace
+	}
 
 	log.Infof("AdmissionReview for %s/%s/%s UID=%v Rfc6902PatchOperation=%v UserInfo=%v",
 		pod.Kind, pod.Namespace, pod.Name, req.UID, req.Operation, req.UserInfo)
 	log.Debugf("Object: %v", string(req.Object.Raw))
 	log.Debugf("OldObject: %v", string(req.OldObject.Raw))
 
-	if !injectRequired(ignoredNamespaces, wh.config.Policy, &pod.ObjectMeta, injectKey, statusKey) {
-		log.Infof("Skipping %s/%s/%s due to policy check", req.Kind, pod.Namespace, pod.Name)
-		return &v1.AdmissionResponse{
-			Allowed: true,
-		}
-	}
-
-	spec, status, err := injectionData(&pod.Spec, &pod.ObjectMeta, wh.config) // nolint: lll
+	patchBytes, reason, err := injectPatch(&pod, wh.config)
 	if err != nil {
-		return toAdmissionResponse(err)
+		// the injection fails only if it is required
+		response := toAdmissionResponse(err)
+		response.AuditAnnotations = injectionAuditAnnotations(true, reason)
+		return response
 	}
-
-	annotations := map[string]string{statusKey: status}
-
-	patchBytes, err := createPatch(&pod, injectionStatus(&pod, statusKey), annotations, spec)
-	if err != nil {
-		return toAdmissionResponse(err)
+	if patchBytes == nil {
+		log.Infof("Skipping %s/%s/%s due to policy check: %s", req.Kind, pod.Namespace, pod.Name, reason)
+		return &v1.AdmissionResponse{
+			Allowed:          true,
+			AuditAnnotations: injectionAuditAnnotations(false, reason),
+		}
 	}
 
 	log.Debugf("AdmissionResponse: patch=%s", string(patchBytes))
@@ -369,17 +402,89 @@ func (wh *Webhook) inject(ar *v1.AdmissionReview) *v1.AdmissionResponse {
 			pt := v1.PatchTypeJSONPatch
 			return &pt
 		}(),
+		AuditAnnotations: injectionAuditAnnotations(true, reason),
 	}
 	return &reviewResponse
 }
 
+// injectionAuditAnnotations records the policy decision in the audit log of the admission.
+func injectionAuditAnnotations(required bool, reason string) map[string]string {
+	return map[string]string{
+		auditAnnotationRequired: strconv.FormatBool(required),
+		auditAnnotationReason:   reason,
+	}
+}
+
 func (wh *Webhook) getCert(*tls.ClientHelloInfo) (*tls.Certificate, error) {
 	wh.mu.Lock()
 	defer wh.mu.Unlock()
 	return wh.cert, nil
 }
 
-func loadConfig(injectFile string) (*WebhookConfig, error) {
+// validate checks the policies, the namespace glob patterns and the injection rules.
+func (w *WebhookConfig) validate() error {
+	if err := w.Policy.validate(); err != nil {
+		return err
+	}
+	patterns := append([]string(nil), w.IgnoredNamespaces...)
+	for _, n := range w.Namespaces {
+		if err := n.Policy.validate(); err != nil {
+			return fmt.Errorf("invalid policy of namespace %q: %v", n.Namespace, err)
+		}
+		patterns = append(patterns, n.Namespace)
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid namespace pattern '%s': %v", pattern, err)
+		}
+	}
+	for i := range w.Rules {
+		if err := w.Rules[i].validate(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forNamespace returns the config with the policy and defaults of the first matching namespace config applied,
+// and the description of where the policy comes from, empty if the global policy applies.
+func (w *WebhookConfig) forNamespace(namespace string) (*WebhookConfig, string) {
+	for _, n := range w.Namespaces {
+		if ok, _ := path.Match(n.Namespace, namespace); !ok {
+			continue
+		}
+		c := *w
+		if n.Policy != "" {
+			c.Policy = n.Policy
+		}
+		c.Default = mergeDefaults(c.Default, n.Default)
+		return &c, fmt.Sprintf("namespace %q", n.Namespace)
+	}
+	return w, ""
+}
+
+// mergeDefaults overrides the defaults with the non-empty values.
+func mergeDefaults(defaults WebhookConfigDefaults, override WebhookConfigDefaults) WebhookConfigDefaults {
+	for _, f := range []struct {
+		target *string
+		value  string
+	}{
+		{&defaults.ContainerName, override.ContainerName},
+		{&defaults.Label, override.Label},
+		{&defaults.Profile, override.Profile},
+		{&defaults.VolumeName, override.VolumeName},
+		{&defaults.VolumeMount, override.VolumeMount},
+		{&defaults.Source, override.Source},
+	} {
+		if f.value != "" {
+			*f.target = f.value
+		}
+	}
+	return defaults
+}
+
+// LoadConfig reads the webhook configuration file.
+func LoadConfig(injectFile string) (*WebhookConfig, error) {
 	data, err := os.ReadFile(injectFile) // #nosec G304
 	if err != nil {
 		return nil, err
@@ -388,6 +493,9 @@ func loadConfig(injectFile string) (*WebhookConfig, error) {
 	if err := yaml.Unmarshal(data, &c); err != nil {
 		return nil, err
 	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
 
 	log.Debugf("Configuration loaded: sha256sum %x", sha256.Sum256(data))
 
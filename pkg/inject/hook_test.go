@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/onsi/gomega"
@@ -80,7 +81,7 @@ const (
 	annotationInjectKey = "config.scccmd.github.com/inject"
 )
 
-func TestInjectRequired(t *testing.T) {
+func TestDecideInjection(t *testing.T) {
 	podSpec := &corev1.PodSpec{}
 	podSpecHostNetwork := &corev1.PodSpec{
 		HostNetwork: true,
@@ -180,11 +181,24 @@ func TestInjectRequired(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			policy:  InjectionPolicyEnabled,
+			podSpec: podSpec,
+			meta: &metav1.ObjectMeta{
+				Name:        "ignored-namespace",
+				Namespace:   metav1.NamespaceSystem,
+				Annotations: map[string]string{annotationInjectKey: "true"},
+			},
+			want: false,
+		},
 	}
 
 	for _, c := range cases {
-		if got := injectRequired(ignoredNamespaces, c.policy, c.meta, annotationInjectKey, "config.scccmd.github.com/status"); got != c.want {
-			t.Errorf("injectRequired(%v, %v) got %v want %v", c.policy, c.meta, got, c.want)
+		// the config built in code ignores the system namespaces too
+		config := &WebhookConfig{Policy: c.policy, AnnotationPrefix: annotationPrefix}
+		pod := &corev1.Pod{ObjectMeta: *c.meta, Spec: *c.podSpec}
+		if _, got, reason := decideInjection(pod, config); got != c.want {
+			t.Errorf("decideInjection(%v, %v) got %v want %v: %s", c.policy, c.meta, got, c.want, reason)
 		}
 	}
 }
@@ -235,6 +249,122 @@ func makeTestData(t testing.TB, skip bool) []byte {
 	return reviewJSON
 }
 
+func TestCalculateImageArgs(t *testing.T) {
+	config := &WebhookConfig{
+		AnnotationPrefix: "config/",
+		Default: WebhookConfigDefaults{
+			Label:   "master",
+			Profile: "default",
+			Source:  "http://config-service",
+		},
+	}
+	podSpec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "c1"}}}
+
+	cases := []struct {
+		annotations map[string]string
+		want        string
+	}{
+		{
+			map[string]string{"config/destination": "config.yaml"},
+			"get values --source http://config-service --application c1 --profile default --label master --destination config.yaml",
+		},
+		{
+			map[string]string{"config/destination": "config.yaml", "config/profile": "default, cloud"},
+			"get values --source http://config-service --application c1 --profile default,cloud --label master --destination config.yaml",
+		},
+	}
+
+	for _, c := range cases {
+		args, err := calculateImageArgs(config, c.annotations, podSpec)
+		if err != nil {
+			t.Fatalf("calculateImageArgs() failed: %v", err)
+		}
+		testutil.AssertString(t, "Incorrect args", c.want, strings.Join(args, " "))
+	}
+}
+
+func TestCalculateDynamicConfigProvenance(t *testing.T) {
+	config := &WebhookConfig{
+		AnnotationPrefix: "config/",
+		Default: WebhookConfigDefaults{
+			Label:       "master",
+			Profile:     "default",
+			Source:      "http://config-service",
+			VolumeMount: "/config",
+		},
+		Provenance: InitContainerProvenance{File: "provenance.json"},
+	}
+	podSpec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "c1"}}}
+	args := "get values --source http://config-service --application c1 --profile default --label master --destination config.yaml"
+
+	cases := []struct {
+		annotations map[string]string
+		annotate    bool
+		want        string
+		provenance  string
+		env         int
+	}{
+		{
+			map[string]string{"config/destination": "config.yaml"},
+			false,
+			args + " --provenance /config/provenance.json",
+			"/config/provenance.json",
+			0,
+		},
+		{
+			map[string]string{"config/destination": "config.yaml", "config/provenance-file": "meta/origin.json", "config/provenance-annotate": "true"},
+			false,
+			args + " --provenance /config/meta/origin.json --provenance-annotation config/provenance",
+			"/config/meta/origin.json",
+			2,
+		},
+		{
+			map[string]string{"config/destination": "config.yaml", "config/provenance-annotate": "off"},
+			true,
+			args + " --provenance /config/provenance.json",
+			"/config/provenance.json",
+			0,
+		},
+		{
+			map[string]string{"config/destination": "config.yaml", "config/provenance-file": ""},
+			true,
+			args,
+			"",
+			0,
+		},
+	}
+
+	for _, c := range cases {
+		config.Provenance.Annotate = c.annotate
+		d, err := calculateDynamicConfig(config, c.annotations, podSpec)
+		if err != nil {
+			t.Fatalf("calculateDynamicConfig() failed: %v", err)
+		}
+		testutil.AssertString(t, "Incorrect args", c.want, strings.Join(d.imageArgs, " "))
+		testutil.AssertString(t, "Incorrect provenance", c.provenance, d.provenance)
+		if env := podEnv(d.annotate); len(env) != c.env {
+			t.Errorf("Expected %d environment variables got %v instead", c.env, env)
+		}
+	}
+
+	// the provenance is disabled by default, older init container images do not know the flag
+	var defaults WebhookConfig
+	if err := yaml.Unmarshal([]byte("annotation-prefix: config/\n"), &defaults); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{"": "", "origin.json": "/config/origin.json"} {
+		annotations := map[string]string{"config/destination": "config.yaml"}
+		if file != "" {
+			annotations["config/provenance-file"] = file
+		}
+		d, err := calculateDynamicConfig(&defaults, annotations, podSpec)
+		if err != nil {
+			t.Fatalf("calculateDynamicConfig() failed: %v", err)
+		}
+		testutil.AssertString(t, "Incorrect default provenance", want, d.provenance)
+	}
+}
+
 func createWebhook(t testing.TB) (*Webhook, func()) {
 	t.Helper()
 	dir, err := os.MkdirTemp("", "webhook_test")
@@ -508,3 +638,184 @@ func checkCert(t *testing.T, wh *Webhook, cert, key []byte) bool {
 	}
 	return bytes.Equal(actual.Certificate[0], expected.Certificate[0])
 }
+
+func TestLoadConfigNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name    string
+		config  string
+		ignored []string
+		wantErr bool
+	}{
+		{
+			name:    "default ignored namespaces",
+			config:  "policy: enabled\n",
+			ignored: []string{"kube-system", "kube-public"},
+		},
+		{
+			name:    "configured ignored namespaces",
+			config:  "ignored-namespaces: [kube-*, monitoring]\n",
+			ignored: []string{"kube-*", "monitoring"},
+		},
+		{
+			name:    "invalid pattern",
+			config:  "namespaces:\n- namespace: team-[\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid namespace policy",
+			config:  "namespaces:\n- namespace: team-a\n  policy: enbled\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid policy",
+			config:  "policy: enbled\n",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			file := filepath.Join(dir, "config.yaml")
+			if err := os.WriteFile(file, []byte(c.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			config, err := LoadConfig(file)
+			if c.wantErr {
+				if err == nil {
+					t.Error("Expected invalid config error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig() failed: %v", err)
+			}
+			testutil.AssertString(t, "Ignored namespaces", strings.Join(c.ignored, ","), strings.Join(config.IgnoredNamespaces, ","))
+		})
+	}
+}
+
+func TestWebhookConfigForNamespace(t *testing.T) {
+	var config WebhookConfig
+	err := yaml.Unmarshal([]byte(`
+policy: disabled
+default:
+  source: http://config-service
+namespaces:
+- namespace: team-a
+  policy: enabled
+  default:
+    source: http://config-a
+    profile: prod
+- namespace: team-*
+  default:
+    label: main
+`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		namespace string
+		policy    InjectionPolicy
+		source    string
+		profile   string
+		label     string
+		scope     string
+	}{
+		{"team-a", InjectionPolicyEnabled, "http://config-a", "prod", "master", `namespace "team-a"`},
+		{"team-b", InjectionPolicyDisabled, "http://config-service", "default", "main", `namespace "team-*"`},
+		{"other", InjectionPolicyDisabled, "http://config-service", "default", "master", ""},
+	}
+
+	for _, c := range cases {
+		scoped, scope := config.forNamespace(c.namespace)
+		testutil.AssertString(t, c.namespace+" policy", string(c.policy), string(scoped.Policy))
+		testutil.AssertString(t, c.namespace+" source", c.source, scoped.Default.Source)
+		testutil.AssertString(t, c.namespace+" profile", c.profile, scoped.Default.Profile)
+		testutil.AssertString(t, c.namespace+" label", c.label, scoped.Default.Label)
+		testutil.AssertString(t, c.namespace+" scope", c.scope, scope)
+	}
+	if config.Default.Source != "http://config-service" || config.Policy != InjectionPolicyDisabled {
+		t.Errorf("Expected the global config untouched got %+v", config)
+	}
+}
+
+func TestInjectNamespaceFromRequest(t *testing.T) {
+	var config WebhookConfig
+	if err := yaml.Unmarshal([]byte("namespaces:\n- namespace: team-a\n  policy: disabled\n"), &config); err != nil {
+		t.Fatal(err)
+	}
+	wh := &Webhook{config: &config}
+
+	raw, _ := json.Marshal(corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "app-", Annotations: map[string]string{annotationPrefix + "destination": "config.yaml"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+	})
+	for namespace, injected := range map[string]bool{"team-a": false, "team-b": true, "kube-system": false} {
+		res := wh.inject(&v1.AdmissionReview{Request: &v1.AdmissionRequest{Namespace: namespace, Object: runtime.RawExtension{Raw: raw}}})
+		if got := len(res.Patch) > 0; got != injected {
+			t.Errorf("Expected injected %v in namespace %s got patch %s", injected, namespace, res.Patch)
+		}
+	}
+}
+
+func TestInjectReinjection(t *testing.T) {
+	var config WebhookConfig
+	if err := yaml.Unmarshal([]byte("default:\n  source: http://config-service\n"), &config); err != nil {
+		t.Fatal(err)
+	}
+	wh := &Webhook{config: &config}
+
+	mount := corev1.VolumeMount{Name: "config-volume", MountPath: "/config"}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Annotations: map[string]string{
+			annotationPrefix + "destination": "config.yaml",
+			annotationPrefix + "status":      `{"initContainers":["config-init"],"volumeMounts":["config-volume"],"volumes":["config-volume"]}`,
+		}},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{
+				{Name: "config-init", VolumeMounts: []corev1.VolumeMount{mount}},
+				{Name: "migrate", VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/data"}, mount}},
+			},
+			Containers: []corev1.Container{{Name: "app", VolumeMounts: []corev1.VolumeMount{mount}}},
+			Volumes:    []corev1.Volume{{Name: "data"}, {Name: "config-volume"}},
+		},
+	}
+	raw, _ := json.Marshal(pod)
+	res := wh.inject(&v1.AdmissionReview{Request: &v1.AdmissionRequest{Namespace: "default", Object: runtime.RawExtension{Raw: raw}}})
+	if res.Result != nil {
+		t.Fatalf("Injection failed: %v", res.Result.Message)
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	patched, err := applyPatch(doc, res.Patch)
+	if err != nil {
+		t.Fatalf("Unable to apply patch %s: %v", res.Patch, err)
+	}
+	data, _ := json.Marshal(patched)
+	var got corev1.Pod
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// the previously injected container, mounts and volume are replaced, not duplicated
+	var names []string
+	for _, c := range got.Spec.InitContainers {
+		var mounts []string
+		for _, m := range c.VolumeMounts {
+			mounts = append(mounts, m.Name)
+		}
+		names = append(names, c.Name+"("+strings.Join(mounts, ",")+")")
+	}
+	testutil.AssertString(t, "Init containers", "config-init(config-volume),migrate(data,config-volume)", strings.Join(names, ","))
+	if len(got.Spec.Containers[0].VolumeMounts) != 1 {
+		t.Errorf("Expected single config mount of the container got %v", got.Spec.Containers[0].VolumeMounts)
+	}
+	if len(got.Spec.Volumes) != 2 {
+		t.Errorf("Expected the config volume replaced got %v", got.Spec.Volumes)
+	}
+}
@@ -3,9 +3,11 @@ package inject
 import (
 	"encoding/json"
 	"fmt"
+	"path"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/wandera/scccmd/pkg/client"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -17,11 +19,12 @@ type InjectionPolicy string
 
 // SidecarInjectionStatus contains basic information about the
 // injected sidecar. This includes the names of added containers and
-// volumes.
+// volumes and the path of the config provenance file.
 type SidecarInjectionStatus struct {
 	InitContainers []string `json:"initContainers"`
 	VolumeMounts   []string `json:"volumeMounts"`
 	Volumes        []string `json:"volumes"`
+	Provenance     string   `json:"provenance,omitempty"`
 }
 
 // SidecarInjectionSpec collects all container types and volumes for
@@ -37,6 +40,8 @@ type dynamicConfig struct {
 	volumeName    string
 	volumeMount   string
 	imageArgs     []string
+	provenance    string
+	annotate      bool
 }
 
 const (
@@ -55,6 +60,16 @@ const (
 	InjectionPolicyEnabled InjectionPolicy = "enabled"
 )
 
+// validate checks the policy is known, empty policy is inherited from the enclosing scope.
+func (p InjectionPolicy) validate() error {
+	switch p {
+	case "", InjectionPolicyDisabled, InjectionPolicyEnabled:
+		return nil
+	default:
+		return fmt.Errorf("unknown policy '%s' expected one of '%s|%s'", p, InjectionPolicyEnabled, InjectionPolicyDisabled)
+	}
+}
+
 // InjectionStatus extracts the injection status from the pod.
 func injectionStatus(pod *corev1.Pod, annotationStatusKey string) *SidecarInjectionStatus {
 	var statusBytes []byte
@@ -109,6 +124,7 @@ func injectionData(spec *corev1.PodSpec, metadata *metav1.ObjectMeta, config *We
 				SecurityContext: &corev1.SecurityContext{
 					AllowPrivilegeEscalation: config.SecurityContext.AllowPrivilegeEscalation,
 				},
+				Env: podEnv(d.annotate),
 			},
 		},
 		VolumeMounts: []corev1.VolumeMount{volumeMount},
@@ -120,7 +136,7 @@ func injectionData(spec *corev1.PodSpec, metadata *metav1.ObjectMeta, config *We
 		},
 	}
 
-	status := &SidecarInjectionStatus{}
+	status := &SidecarInjectionStatus{Provenance: d.provenance}
 	for _, c := range sic.InitContainers {
 		status.InitContainers = append(status.InitContainers, c.Name)
 	}
@@ -137,14 +153,86 @@ func injectionData(spec *corev1.PodSpec, metadata *metav1.ObjectMeta, config *We
 	return &sic, string(statusAnnotationValue), nil
 }
 
-func injectRequired(ignored []string, namespacePolicy InjectionPolicy, metadata *metav1.ObjectMeta, annotationInjectKey, annotationStatusKey string) bool { // nolint: lll
-	// skip special kubernetes system namespaces
-	for _, namespace := range ignored {
-		if metadata.Namespace == namespace {
-			return false
+// podEnv environment variables identifying the pod, so the init container can annotate it.
+func podEnv(annotate bool) []corev1.EnvVar {
+	if !annotate {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{
+			Name:      "POD_NAME",
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}},
+		},
+		{
+			Name:      "POD_NAMESPACE",
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"}},
+		},
+	}
+}
+
+// parseBool parses the annotation value as YAML boolean, the default is used for empty or unknown value.
+func parseBool(value string, def bool) bool {
+	// http://yaml.org/type/bool.html
+	switch strings.ToLower(value) {
+	case "y", "yes", "true", "on":
+		return true
+	case "n", "no", "false", "off":
+		return false
+	}
+	return def
+}
+
+// injectPatch creates rfc6902 patch injecting the config init container into the pod and explains the policy decision,
+// the patch is nil if the injection is not required by the policy.
+func injectPatch(pod *corev1.Pod, config *WebhookConfig) ([]byte, string, error) {
+	config, required, reason := decideInjection(pod, config)
+	if !required {
+		return nil, reason, nil
+	}
+	statusKey := config.AnnotationPrefix + "status"
+
+	spec, status, err := injectionData(&pod.Spec, &pod.ObjectMeta, config)
+	if err != nil {
+		return nil, reason, err
+	}
+
+	annotations := map[string]string{statusKey: status}
+	patch, err := createPatch(pod, injectionStatus(pod, statusKey), annotations, spec)
+	return patch, reason, err
+}
+
+// decideInjection returns the config effective for the pod, whether the injection is required and the reason.
+func decideInjection(pod *corev1.Pod, config *WebhookConfig) (*WebhookConfig, bool, string) {
+	config, scope := config.forNamespace(pod.Namespace)
+	// the rules are more specific than the namespace configs
+	config, ruleScope := config.forPod(pod)
+	if ruleScope != "" {
+		scope = ruleScope
+	}
+	ignored := config.IgnoredNamespaces
+	if ignored == nil {
+		ignored = ignoredNamespaces
+	}
+	required, reason := explainInjection(ignored, config.Policy, scope, &pod.ObjectMeta,
+		config.AnnotationPrefix+"inject", config.AnnotationPrefix+"status")
+	return config, required, reason
+}
+
+// explainInjection decides whether the injection is required and explains the decision in human readable form,
+// the scope describes where the policy comes from, empty for the global policy.
+func explainInjection(ignored []string, namespacePolicy InjectionPolicy, scope string, metadata *metav1.ObjectMeta, annotationInjectKey, annotationStatusKey string) (bool, string) { // nolint: lll
+	// skip special kubernetes system namespaces and the configured ones
+	for _, pattern := range ignored {
+		if ok, _ := path.Match(pattern, metadata.Namespace); ok {
+			return false, fmt.Sprintf("namespace %q is ignored by %q", metadata.Namespace, pattern)
 		}
 	}
 
+	policy := fmt.Sprintf("%q policy", namespacePolicy)
+	if scope != "" {
+		policy += " of " + scope
+	}
+
 	annotations := metadata.GetAnnotations()
 	if annotations == nil {
 		annotations = map[string]string{}
@@ -161,20 +249,18 @@ func injectRequired(ignored []string, namespacePolicy InjectionPolicy, metadata
 	}
 
 	var required bool
+	var reason string
 	switch namespacePolicy {
 	default: // InjectionPolicyOff
 		required = false
-	case InjectionPolicyDisabled:
+		reason = fmt.Sprintf("the %s turns the injection off", policy)
+	case InjectionPolicyDisabled, InjectionPolicyEnabled:
 		if useDefault {
-			required = false
-		} else {
-			required = inject
-		}
-	case InjectionPolicyEnabled:
-		if useDefault {
-			required = true
+			required = namespacePolicy == InjectionPolicyEnabled
+			reason = fmt.Sprintf("no %q annotation, the %s applies", annotationInjectKey, policy)
 		} else {
 			required = inject
+			reason = fmt.Sprintf("annotation %s=%q overrides the %s", annotationInjectKey, annotations[annotationInjectKey], policy)
 		}
 	}
 
@@ -183,7 +269,10 @@ func injectRequired(ignored []string, namespacePolicy InjectionPolicy, metadata
 	log.Infof("Sidecar injection policy for %v/%v: namespacePolicy:%v useDefault:%v inject:%v status:%q required:%v",
 		metadata.Namespace, metadata.Name, namespacePolicy, useDefault, inject, status, required)
 
-	return required
+	if required && status != "" {
+		reason += ", the previous injection is replaced"
+	}
+	return required, reason
 }
 
 func calculateImageArgs(c *WebhookConfig, a map[string]string, podSpec *corev1.PodSpec) ([]string, error) {
@@ -224,7 +313,10 @@ func calculateImageArgs(c *WebhookConfig, a map[string]string, podSpec *corev1.P
 		}
 	}
 
-	return append([]string{"get", mode, "--source", source, "--application", application, "--profile", profile, "--label", label}, extra...), nil
+	// profiles are passed as single comma separated value, the images with plain string profile flag accept it as well
+	args := []string{"get", mode, "--source", source, "--application", application,
+		"--profile", strings.Join(client.SplitList(profile), ","), "--label", label}
+	return append(args, extra...), nil
 }
 
 func calculateDynamicConfig(c *WebhookConfig, a map[string]string, podSpec *corev1.PodSpec) (*dynamicConfig, error) {
@@ -244,6 +336,22 @@ func calculateDynamicConfig(c *WebhookConfig, a map[string]string, podSpec *core
 	}
 
 	imageArgs, err := calculateImageArgs(c, a, podSpec)
+	if err != nil {
+		return nil, err
+	}
 	d.imageArgs = imageArgs
-	return &d, err
+
+	file, ok := a[c.AnnotationPrefix+"provenance-file"]
+	if !ok {
+		file = c.Provenance.File
+	}
+	if file != "" {
+		d.provenance = path.Join(d.volumeMount, file)
+		d.annotate = parseBool(a[c.AnnotationPrefix+"provenance-annotate"], c.Provenance.Annotate)
+		d.imageArgs = append(d.imageArgs, "--provenance", d.provenance)
+		if d.annotate {
+			d.imageArgs = append(d.imageArgs, "--provenance-annotation", c.AnnotationPrefix+"provenance")
+		}
+	}
+	return &d, nil
 }
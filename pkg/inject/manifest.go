@@ -0,0 +1,178 @@
+package inject
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+)
+
+const documentSeparator = "---\n"
+
+// podTemplatePaths paths of the pod template in the supported workload kinds, empty path is the object itself.
+var podTemplatePaths = map[string][]string{
+	"Pod":                   {},
+	"Deployment":            {"spec", "template"},
+	"StatefulSet":           {"spec", "template"},
+	"DaemonSet":             {"spec", "template"},
+	"ReplicaSet":            {"spec", "template"},
+	"ReplicationController": {"spec", "template"},
+	"Job":                   {"spec", "template"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template"},
+}
+
+// podOwners controllers owning the pods of the workload kinds, the pods of Deployments are owned by ReplicaSets
+// and the pods of CronJobs by Jobs.
+var podOwners = map[string]metav1.TypeMeta{
+	"Deployment":            {APIVersion: "apps/v1", Kind: "ReplicaSet"},
+	"StatefulSet":           {APIVersion: "apps/v1", Kind: "StatefulSet"},
+	"DaemonSet":             {APIVersion: "apps/v1", Kind: "DaemonSet"},
+	"ReplicaSet":            {APIVersion: "apps/v1", Kind: "ReplicaSet"},
+	"ReplicationController": {APIVersion: "v1", Kind: "ReplicationController"},
+	"Job":                   {APIVersion: "batch/v1", Kind: "Job"},
+	"CronJob":               {APIVersion: "batch/v1", Kind: "Job"},
+}
+
+// podOwnerReferences owner references the pods of the workload get on admission, nil for Pods.
+func podOwnerReferences(kind string, name string) []metav1.OwnerReference {
+	owner, ok := podOwners[kind]
+	if !ok {
+		return nil
+	}
+	return []metav1.OwnerReference{{APIVersion: owner.APIVersion, Kind: owner.Kind, Name: name, Controller: ptr.To(true)}}
+}
+
+// InjectManifests reads the multi-document Kubernetes YAML manifests and writes them with the config init container
+// injected into the pods and pod templates of the workloads, the same as the Webhook does it on admission.
+// The injection policy and the pod annotations are respected, other documents are written unchanged.
+// The namespace is used for the objects without metadata.namespace.
+func InjectManifests(r io.Reader, w io.Writer, namespace string, config *WebhookConfig) error {
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(r))
+	first := true
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		out, err := injectDocument(doc, namespace, config)
+		if err != nil {
+			return err
+		}
+
+		if !first {
+			if _, err := io.WriteString(w, documentSeparator); err != nil {
+				return err
+			}
+		}
+		first = false
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+}
+
+// injectDocument injects the single YAML document, the document is returned as is if nothing was injected.
+func injectDocument(doc []byte, namespace string, config *WebhookConfig) ([]byte, error) {
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal(doc, &obj); err != nil {
+		return nil, fmt.Errorf("unable to parse manifest: %v", err)
+	}
+	if obj == nil {
+		return doc, nil
+	}
+
+	injected, err := injectObject(obj, namespace, config)
+	if err != nil || !injected {
+		return doc, err
+	}
+	return yaml.Marshal(obj)
+}
+
+// injectObject injects the pod template of the object in place, List items are injected one by one.
+func injectObject(obj map[string]interface{}, namespace string, config *WebhookConfig) (bool, error) {
+	kind, _ := obj["kind"].(string)
+	if strings.HasSuffix(kind, "List") {
+		items, _ := obj["items"].([]interface{})
+		injected := false
+		for _, item := range items {
+			if o, ok := item.(map[string]interface{}); ok {
+				ok, err := injectObject(o, namespace, config)
+				if err != nil {
+					return false, err
+				}
+				injected = injected || ok
+			}
+		}
+		return injected, nil
+	}
+
+	templatePath, ok := podTemplatePaths[kind]
+	if !ok {
+		return false, nil
+	}
+	template := obj
+	for _, key := range templatePath {
+		if template, ok = template[key].(map[string]interface{}); !ok {
+			return false, nil
+		}
+	}
+
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if ns, _ := metadata["namespace"].(string); ns != "" {
+		namespace = ns
+	}
+	injected, err := injectTemplate(template, kind, name, namespace, config)
+	if err != nil {
+		return false, fmt.Errorf("unable to inject %s %s: %v", kind, name, err)
+	}
+	return injected, nil
+}
+
+// injectTemplate injects the pod or pod template in place, the kind, name and namespace are the ones of the owning object.
+// Returns false if the injection is not required by the policy.
+func injectTemplate(template map[string]interface{}, kind, name, namespace string, config *WebhookConfig) (bool, error) {
+	raw, err := json.Marshal(map[string]interface{}{"metadata": template["metadata"], "spec": template["spec"]})
+	if err != nil {
+		return false, err
+	}
+	var pod corev1.Pod
+	if err := json.Unmarshal(raw, &pod); err != nil {
+		return false, err
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = namespace
+	}
+	if len(pod.OwnerReferences) == 0 {
+		pod.OwnerReferences = podOwnerReferences(kind, name)
+	}
+
+	patch, _, err := injectPatch(&pod, config)
+	if err != nil || patch == nil {
+		return false, err
+	}
+
+	if _, ok := template["metadata"].(map[string]interface{}); !ok {
+		template["metadata"] = map[string]interface{}{}
+	}
+	if _, err := applyPatch(template, patch); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,215 @@
+package inject
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"gopkg.in/yaml.v2"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+const testManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: default
+spec:
+  replicas: 2
+  template:
+    metadata:
+      annotations:
+        config.scccmd.github.com/destination: /config/app.yaml
+    spec:
+      containers:
+      - name: app
+        image: app:1.0
+---
+# services are not injected
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: report
+spec:
+  schedule: "@daily"
+  jobTemplate:
+    spec:
+      template:
+        metadata:
+          annotations:
+            config.scccmd.github.com/mapping: report.yaml:/config/report.yaml
+        spec:
+          initContainers:
+          - name: migrate
+            image: migrate:1.0
+          containers:
+          - name: report
+            image: report:1.0
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: opted-out
+  annotations:
+    config.scccmd.github.com/inject: "false"
+spec:
+  containers:
+  - name: app
+    image: app:1.0
+`
+
+func testConfig(t *testing.T) *WebhookConfig {
+	t.Helper()
+	var config WebhookConfig
+	if err := yaml.Unmarshal([]byte("default:\n  source: http://config-service\n"), &config); err != nil {
+		t.Fatal(err)
+	}
+	return &config
+}
+
+func TestInjectManifests(t *testing.T) {
+	var out bytes.Buffer
+	if err := InjectManifests(strings.NewReader(testManifests), &out, "default", testConfig(t)); err != nil {
+		t.Fatalf("InjectManifests() failed: %v", err)
+	}
+
+	docs := strings.Split(out.String(), "\n"+documentSeparator)
+	if len(docs) != 4 {
+		t.Fatalf("Expected 4 documents got %d instead:\n%s", len(docs), out.String())
+	}
+
+	var deployment appsv1.Deployment
+	if err := sigsyaml.Unmarshal([]byte(docs[0]), &deployment); err != nil {
+		t.Fatal(err)
+	}
+	spec := deployment.Spec.Template.Spec
+	if len(spec.InitContainers) != 1 || len(spec.Volumes) != 1 || len(spec.Containers[0].VolumeMounts) != 1 {
+		t.Fatalf("Expected init container, volume and volume mount injected got %+v", spec)
+	}
+	testutil.AssertString(t, "Init container args",
+		"get values --source http://config-service --application app --profile default --label master --destination /config/app.yaml",
+		strings.Join(spec.InitContainers[0].Args, " "))
+	if *deployment.Spec.Replicas != 2 {
+		t.Errorf("Expected replicas preserved got %d", *deployment.Spec.Replicas)
+	}
+	if deployment.Spec.Template.Annotations[annotationPrefix+"status"] == "" {
+		t.Error("Expected injection status annotation")
+	}
+
+	testutil.AssertString(t, "Service unchanged", "# services are not injected\napiVersion: v1\nkind: Service\nmetadata:\n  name: app\nspec:\n  ports:\n  - port: 80", docs[1])
+
+	var cronJob batchv1.CronJob
+	if err := sigsyaml.Unmarshal([]byte(docs[2]), &cronJob); err != nil {
+		t.Fatal(err)
+	}
+	initContainers := cronJob.Spec.JobTemplate.Spec.Template.Spec.InitContainers
+	if len(initContainers) != 2 || initContainers[0].Name != "config-init" || initContainers[1].Name != "migrate" {
+		t.Errorf("Expected config init container injected first got %+v", initContainers)
+	}
+
+	var pod corev1.Pod
+	if err := sigsyaml.Unmarshal([]byte(docs[3]), &pod); err != nil {
+		t.Fatal(err)
+	}
+	if len(pod.Spec.InitContainers) != 0 {
+		t.Errorf("Expected opted out pod not injected got %+v", pod.Spec.InitContainers)
+	}
+}
+
+func TestInjectManifestsIdempotent(t *testing.T) {
+	config := testConfig(t)
+	var first, second bytes.Buffer
+	if err := InjectManifests(strings.NewReader(testManifests), &first, "default", config); err != nil {
+		t.Fatal(err)
+	}
+	if err := InjectManifests(bytes.NewReader(first.Bytes()), &second, "default", config); err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertString(t, "Injection is not idempotent", first.String(), second.String())
+}
+
+func TestInjectManifestsList(t *testing.T) {
+	list := `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: app
+    annotations:
+      config.scccmd.github.com/destination: /config/app.yaml
+  spec:
+    containers:
+    - name: app
+`
+	var out bytes.Buffer
+	if err := InjectManifests(strings.NewReader(list), &out, "default", testConfig(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	var l corev1.List
+	if err := sigsyaml.Unmarshal(out.Bytes(), &l); err != nil {
+		t.Fatal(err)
+	}
+	var pod corev1.Pod
+	if err := sigsyaml.Unmarshal(l.Items[0].Raw, &pod); err != nil {
+		t.Fatal(err)
+	}
+	if len(pod.Spec.InitContainers) != 1 {
+		t.Errorf("Expected List item injected got %s", out.String())
+	}
+}
+
+func TestInjectManifestsNamespace(t *testing.T) {
+	pod := `apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+  annotations:
+    config.scccmd.github.com/destination: /config/app.yaml
+spec:
+  containers:
+  - name: app
+`
+	cases := []struct {
+		namespace string
+		manifest  string
+		injected  bool
+	}{
+		{"default", pod, true},
+		// the system namespaces are ignored by default
+		{"kube-system", pod, false},
+		{"kube-system", strings.Replace(pod, "  name: app\n", "  name: app\n  namespace: default\n", 1), true},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		if err := InjectManifests(strings.NewReader(c.manifest), &out, c.namespace, testConfig(t)); err != nil {
+			t.Fatal(err)
+		}
+		if injected := strings.Contains(out.String(), "config-init"); injected != c.injected {
+			t.Errorf("Expected injected %v in namespace %s got %s", c.injected, c.namespace, out.String())
+		}
+	}
+}
+
+func TestInjectManifestsInvalid(t *testing.T) {
+	missing := "apiVersion: v1\nkind: Pod\nmetadata:\n  name: app\nspec:\n  containers:\n  - name: app\n"
+	if err := InjectManifests(strings.NewReader(missing), &bytes.Buffer{}, "default", testConfig(t)); err == nil {
+		t.Error("Expected error for pod without mapping and destination annotations")
+	}
+	if err := InjectManifests(strings.NewReader("kind: [Pod"), &bytes.Buffer{}, "default", testConfig(t)); err == nil {
+		t.Error("Expected parse error")
+	}
+}
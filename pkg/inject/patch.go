@@ -3,6 +3,7 @@ package inject
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -48,14 +49,29 @@ func removeVolumeMounts(volumeMounts []corev1.VolumeMount, removed []string, pat
 	return patch
 }
 
-func removeAllVolumeMounts(containers []corev1.Container, volumeMounts []string) []rfc6902PatchOperation {
+func removeAllVolumeMounts(containers []corev1.Container, volumeMounts []string, path string) []rfc6902PatchOperation {
 	var patch []rfc6902PatchOperation
 	for i, container := range containers {
-		patch = append(patch, removeVolumeMounts(container.VolumeMounts, volumeMounts, fmt.Sprintf("/spec/containers/%d/volumeMounts", i))...)
+		patch = append(patch, removeVolumeMounts(container.VolumeMounts, volumeMounts, fmt.Sprintf(path, i))...)
 	}
 	return patch
 }
 
+// remainingContainers containers left after the removed ones are removed.
+func remainingContainers(containers []corev1.Container, removed []string) []corev1.Container {
+	names := map[string]bool{}
+	for _, name := range removed {
+		names[name] = true
+	}
+	var remaining []corev1.Container
+	for _, c := range containers {
+		if !names[c.Name] {
+			remaining = append(remaining, c)
+		}
+	}
+	return remaining
+}
+
 func removeVolumes(volumes []corev1.Volume, removed []string, path string) (patch []rfc6902PatchOperation) {
 	names := map[string]bool{}
 	for _, name := range removed {
@@ -181,16 +197,113 @@ func createPatch(pod *corev1.Pod, prevStatus *SidecarInjectionStatus, annotation
 
 	// Remove any containers previously injected by kube-inject using
 	// container and volume name as unique key for removal.
+	// The operations are applied sequentially, so the following ones
+	// address the init containers left after the removal.
+	initContainers := remainingContainers(pod.Spec.InitContainers, prevStatus.InitContainers)
 	patch = append(patch, removeContainers(pod.Spec.InitContainers, prevStatus.InitContainers, "/spec/initContainers")...)
-	patch = append(patch, removeAllVolumeMounts(pod.Spec.Containers, prevStatus.VolumeMounts)...)
+	patch = append(patch, removeAllVolumeMounts(initContainers, prevStatus.VolumeMounts, "/spec/initContainers/%d/volumeMounts")...)
+	patch = append(patch, removeAllVolumeMounts(pod.Spec.Containers, prevStatus.VolumeMounts, "/spec/containers/%d/volumeMounts")...)
 	patch = append(patch, removeVolumes(pod.Spec.Volumes, prevStatus.Volumes, "/spec/volumes")...)
 
-	patch = append(patch, addAllVolumeMounts(pod.Spec.InitContainers, sic.VolumeMounts, "/spec/initContainers/%d/volumeMounts")...)
+	patch = append(patch, addAllVolumeMounts(initContainers, sic.VolumeMounts, "/spec/initContainers/%d/volumeMounts")...)
 	patch = append(patch, addAllVolumeMounts(pod.Spec.Containers, sic.VolumeMounts, "/spec/containers/%d/volumeMounts")...)
 	patch = append(patch, addVolume(pod.Spec.Volumes, sic.Volumes, "/spec/volumes")...)
-	patch = append(patch, insertContainer(pod.Spec.InitContainers, sic.InitContainers, "/spec/initContainers", "0")...)
+	patch = append(patch, insertContainer(initContainers, sic.InitContainers, "/spec/initContainers", "0")...)
 
 	patch = append(patch, updateAnnotation(pod.Annotations, annotations)...)
 
 	return json.Marshal(patch)
 }
+
+// applyPatch applies the rfc6902 patch created by createPatch to the JSON document,
+// only the operations used by the injection are supported.
+func applyPatch(doc interface{}, patch []byte) (interface{}, error) {
+	var ops []struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value,omitempty"`
+	}
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, err
+	}
+
+	for _, op := range ops {
+		if !strings.HasPrefix(op.Path, "/") {
+			return nil, fmt.Errorf("invalid patch path '%s'", op.Path)
+		}
+		var tokens []string
+		for _, token := range strings.Split(op.Path[1:], "/") {
+			tokens = append(tokens, unescapeJSONPointerValue(token))
+		}
+
+		var err error
+		if doc, err = applyOperation(doc, tokens, op.Op, op.Value); err != nil {
+			return nil, fmt.Errorf("unable to %s %s: %v", op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// applyOperation applies the operation at the path tokens relative to the node and returns the changed node.
+func applyOperation(node interface{}, tokens []string, op string, value interface{}) (interface{}, error) {
+	token := tokens[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(tokens) > 1 {
+			child, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("missing '%s'", token)
+			}
+			changed, err := applyOperation(child, tokens[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			n[token] = changed
+			return n, nil
+		}
+		switch op {
+		case "add", "replace":
+			n[token] = value
+		case "remove":
+			delete(n, token)
+		default:
+			return nil, fmt.Errorf("unsupported operation")
+		}
+		return n, nil
+	case []interface{}:
+		if len(tokens) == 1 && op == "add" && token == "-" {
+			return append(n, value), nil
+		}
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i > len(n) || (i == len(n) && !(op == "add" && len(tokens) == 1)) {
+			return nil, fmt.Errorf("invalid index '%s'", token)
+		}
+		if len(tokens) > 1 {
+			changed, err := applyOperation(n[i], tokens[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			n[i] = changed
+			return n, nil
+		}
+		switch op {
+		case "add":
+			return append(n[:i], append([]interface{}{value}, n[i:]...)...), nil
+		case "replace":
+			n[i] = value
+			return n, nil
+		case "remove":
+			return append(n[:i], n[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("unsupported operation")
+		}
+	default:
+		return nil, fmt.Errorf("'%s' is not an object nor an array", token)
+	}
+}
+
+// unescape JSON Pointer value per https://tools.ietf.org/html/rfc6901.
+func unescapeJSONPointerValue(in string) string {
+	step := strings.ReplaceAll(in, "~1", "/")
+	return strings.ReplaceAll(step, "~0", "~")
+}
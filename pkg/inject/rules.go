@@ -0,0 +1,186 @@
+package inject
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// InjectionRule injection policy and defaults of the pods matching all the selectors of the rule,
+// the omitted selectors match any pod. Empty policy, defaults and resources are inherited.
+type InjectionRule struct {
+	Name string `yaml:"name,omitempty"`
+
+	// Selector selects the pods by their labels
+	Selector *LabelSelector `yaml:"selector,omitempty"`
+
+	// NamespaceSelector selects the namespaces of the pods, the webhook does not read the namespace objects
+	// so only the kubernetes.io/metadata.name label set by Kubernetes is supported
+	NamespaceSelector *LabelSelector `yaml:"namespace-selector,omitempty"`
+
+	// OwnerKinds kinds of the controllers owning the pods, e.g. ReplicaSet for Deployments or Job for CronJobs
+	OwnerKinds []string `yaml:"owner-kinds,omitempty"`
+
+	Policy         InjectionPolicy        `yaml:"policy,omitempty"`
+	ContainerImage string                 `yaml:"container-image,omitempty"`
+	Default        WebhookConfigDefaults  `yaml:"default,omitempty"`
+	Resources      InitContainerResources `yaml:"resources,omitempty"`
+
+	// selector and namespaceSelector parsed by validate when the config is loaded
+	selector          labels.Selector
+	namespaceSelector labels.Selector
+}
+
+// LabelSelector selects by labels the same way as the Kubernetes label selector.
+type LabelSelector struct {
+	MatchLabels      map[string]string          `yaml:"matchLabels,omitempty"`
+	MatchExpressions []LabelSelectorRequirement `yaml:"matchExpressions,omitempty"`
+}
+
+// LabelSelectorRequirement label selector requirement, the operator is one of In, NotIn, Exists and DoesNotExist.
+type LabelSelectorRequirement struct {
+	Key      string   `yaml:"key"`
+	Operator string   `yaml:"operator"`
+	Values   []string `yaml:"values,omitempty"`
+}
+
+func (s *LabelSelector) selector() (labels.Selector, error) {
+	if s == nil {
+		return labels.Everything(), nil
+	}
+	ls := &metav1.LabelSelector{MatchLabels: s.MatchLabels}
+	for _, r := range s.MatchExpressions {
+		ls.MatchExpressions = append(ls.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      r.Key,
+			Operator: metav1.LabelSelectorOperator(r.Operator),
+			Values:   r.Values,
+		})
+	}
+	return metav1.LabelSelectorAsSelector(ls)
+}
+
+// keys label keys the selector uses.
+func (s *LabelSelector) keys() []string {
+	if s == nil {
+		return nil
+	}
+	var keys []string
+	for key := range s.MatchLabels {
+		keys = append(keys, key)
+	}
+	for _, r := range s.MatchExpressions {
+		keys = append(keys, r.Key)
+	}
+	return keys
+}
+
+// compiled returns the selector parsed on load, the configs not loaded by LoadConfig are parsed on use.
+// Invalid selector matches nothing.
+func compiled(parsed labels.Selector, s *LabelSelector) labels.Selector {
+	if parsed != nil {
+		return parsed
+	}
+	selector, err := s.selector()
+	if err != nil {
+		return labels.Nothing()
+	}
+	return selector
+}
+
+// description name of the rule, or its position if not named.
+func (r *InjectionRule) description(i int) string {
+	if r.Name != "" {
+		return fmt.Sprintf("rule %q", r.Name)
+	}
+	return fmt.Sprintf("rule #%d", i+1)
+}
+
+// validate checks the rule and parses its selectors.
+func (r *InjectionRule) validate(i int) error {
+	if err := r.Policy.validate(); err != nil {
+		return fmt.Errorf("invalid policy of %s: %v", r.description(i), err)
+	}
+	var err error
+	if r.selector, err = r.Selector.selector(); err != nil {
+		return fmt.Errorf("invalid selector of %s: %v", r.description(i), err)
+	}
+	if r.namespaceSelector, err = r.NamespaceSelector.selector(); err != nil {
+		return fmt.Errorf("invalid namespace selector of %s: %v", r.description(i), err)
+	}
+	// the webhook does not read the namespace objects, the other labels would silently match nothing
+	for _, key := range r.NamespaceSelector.keys() {
+		if key != corev1.LabelMetadataName {
+			return fmt.Errorf("invalid namespace selector of %s: only the %s label is supported, got '%s'",
+				r.description(i), corev1.LabelMetadataName, key)
+		}
+	}
+	for _, q := range []string{r.Resources.Requests.CPU, r.Resources.Requests.Memory, r.Resources.Limits.CPU, r.Resources.Limits.Memory} {
+		if q == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(q); err != nil {
+			return fmt.Errorf("invalid resources of %s: %v", r.description(i), err)
+		}
+	}
+	return nil
+}
+
+func (r *InjectionRule) matches(pod *corev1.Pod) bool {
+	if !compiled(r.selector, r.Selector).Matches(labels.Set(pod.Labels)) {
+		return false
+	}
+	if !compiled(r.namespaceSelector, r.NamespaceSelector).Matches(labels.Set{corev1.LabelMetadataName: pod.Namespace}) {
+		return false
+	}
+	if len(r.OwnerKinds) == 0 {
+		return true
+	}
+	for _, owner := range pod.OwnerReferences {
+		for _, kind := range r.OwnerKinds {
+			if owner.Kind == kind {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// forPod returns the config with the policy, image, defaults and resources of the first rule matching the pod applied,
+// and the description of the rule if it sets the policy, empty otherwise.
+func (w *WebhookConfig) forPod(pod *corev1.Pod) (*WebhookConfig, string) {
+	for i := range w.Rules {
+		r := &w.Rules[i]
+		if !r.matches(pod) {
+			continue
+		}
+		c := *w
+		if r.ContainerImage != "" {
+			c.ContainerImage = r.ContainerImage
+		}
+		c.Default = mergeDefaults(c.Default, r.Default)
+		c.Resources = InitContainerResources{
+			Requests: mergeResources(c.Resources.Requests, r.Resources.Requests),
+			Limits:   mergeResources(c.Resources.Limits, r.Resources.Limits),
+		}
+		if r.Policy == "" {
+			return &c, ""
+		}
+		c.Policy = r.Policy
+		return &c, r.description(i)
+	}
+	return w, ""
+}
+
+// mergeResources overrides the resources with the non-empty values.
+func mergeResources(resources InitContainerResourcesList, override InitContainerResourcesList) InitContainerResourcesList {
+	if override.CPU != "" {
+		resources.CPU = override.CPU
+	}
+	if override.Memory != "" {
+		resources.Memory = override.Memory
+	}
+	return resources
+}
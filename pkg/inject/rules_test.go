@@ -0,0 +1,243 @@
+package inject
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testRules = `
+policy: disabled
+default:
+  source: http://config-service
+rules:
+- name: java
+  selector:
+    matchLabels:
+      runtime: java
+    matchExpressions:
+    - key: tier
+      operator: NotIn
+      values: [batch]
+  policy: enabled
+  container-image: scccmd:java
+  default:
+    profile: java
+  resources:
+    limits:
+      memory: 100M
+- name: jobs
+  owner-kinds: [Job]
+  namespace-selector:
+    matchExpressions:
+    - key: kubernetes.io/metadata.name
+      operator: In
+      values: [batch, cron]
+  policy: enabled
+  default:
+    label: jobs
+- selector:
+    matchLabels:
+      app: legacy
+  default:
+    label: legacy
+`
+
+func TestInjectionRules(t *testing.T) {
+	var config WebhookConfig
+	if err := yaml.Unmarshal([]byte(testRules), &config); err != nil {
+		t.Fatal(err)
+	}
+	// the selectors are parsed once on load
+	if err := config.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if config.Rules[0].selector == nil || config.Rules[1].namespaceSelector == nil {
+		t.Fatal("Expected the selectors parsed on load")
+	}
+
+	job := []metav1.OwnerReference{{Kind: "Job", Name: "import"}}
+	cases := []struct {
+		name        string
+		namespace   string
+		labels      map[string]string
+		owners      []metav1.OwnerReference
+		annotations map[string]string
+		required    bool
+		reason      string
+		image       string
+		label       string
+	}{
+		{
+			name:     "pod labels",
+			labels:   map[string]string{"runtime": "java"},
+			required: true,
+			reason:   `no "config.scccmd.github.com/inject" annotation, the "enabled" policy of rule "java" applies`,
+			image:    "scccmd:java",
+			label:    "master",
+		},
+		{
+			name:        "annotation overrides rule",
+			labels:      map[string]string{"runtime": "java"},
+			annotations: map[string]string{"config.scccmd.github.com/inject": "false"},
+			required:    false,
+			reason:      `annotation config.scccmd.github.com/inject="false" overrides the "enabled" policy of rule "java"`,
+			image:       "scccmd:java",
+			label:       "master",
+		},
+		{
+			name:     "match expression excludes",
+			labels:   map[string]string{"runtime": "java", "tier": "batch"},
+			required: false,
+			reason:   `no "config.scccmd.github.com/inject" annotation, the "disabled" policy applies`,
+			image:    "wanderadock/scccmd",
+			label:    "master",
+		},
+		{
+			name:      "owner kind and namespace",
+			namespace: "cron",
+			owners:    job,
+			required:  true,
+			reason:    `no "config.scccmd.github.com/inject" annotation, the "enabled" policy of rule "jobs" applies`,
+			image:     "wanderadock/scccmd",
+			label:     "jobs",
+		},
+		{
+			name:      "owner kind in other namespace",
+			namespace: "default",
+			owners:    job,
+			required:  false,
+			reason:    `no "config.scccmd.github.com/inject" annotation, the "disabled" policy applies`,
+			image:     "wanderadock/scccmd",
+			label:     "master",
+		},
+		{
+			name:     "rule without policy",
+			labels:   map[string]string{"app": "legacy"},
+			required: false,
+			reason:   `no "config.scccmd.github.com/inject" annotation, the "disabled" policy applies`,
+			image:    "wanderadock/scccmd",
+			label:    "legacy",
+		},
+		{
+			name:      "ignored namespace",
+			namespace: "kube-system",
+			labels:    map[string]string{"runtime": "java"},
+			required:  false,
+			reason:    `namespace "kube-system" is ignored by "kube-system"`,
+			image:     "scccmd:java",
+			label:     "master",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:       c.namespace,
+				Labels:          c.labels,
+				Annotations:     c.annotations,
+				OwnerReferences: c.owners,
+			}}
+			scoped, required, reason := decideInjection(pod, &config)
+			if required != c.required {
+				t.Errorf("Expected required %v got %v", c.required, required)
+			}
+			testutil.AssertString(t, "Reason", c.reason, reason)
+			testutil.AssertString(t, "Image", c.image, scoped.ContainerImage)
+			testutil.AssertString(t, "Label", c.label, scoped.Default.Label)
+		})
+	}
+}
+
+func TestInjectionRuleResources(t *testing.T) {
+	var config WebhookConfig
+	if err := yaml.Unmarshal([]byte(testRules), &config); err != nil {
+		t.Fatal(err)
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"runtime": "java"}}}
+	scoped, _ := config.forPod(pod)
+	testutil.AssertString(t, "Memory limit", "100M", scoped.Resources.Limits.Memory)
+	testutil.AssertString(t, "CPU limit", config.Resources.Limits.CPU, scoped.Resources.Limits.CPU)
+	testutil.AssertString(t, "Memory request", config.Resources.Requests.Memory, scoped.Resources.Requests.Memory)
+	testutil.AssertString(t, "Profile", "java", scoped.Default.Profile)
+}
+
+func TestLoadConfigInvalidRules(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"invalid operator": "rules:\n- selector:\n    matchExpressions:\n    - key: app\n      operator: Equals\n",
+		"invalid label":    "rules:\n- namespace-selector:\n    matchLabels:\n      '-app': x\n",
+		"invalid quantity": "rules:\n- resources:\n    limits:\n      cpu: lots\n",
+		"namespace label":  "rules:\n- namespace-selector:\n    matchLabels:\n      team: a\n",
+		"namespace key":    "rules:\n- namespace-selector:\n    matchExpressions:\n    - key: team\n      operator: Exists\n",
+		"invalid policy":   "rules:\n- policy: enbled\n",
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(dir, "config.yaml")
+			if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadConfig(file); err == nil || !strings.Contains(err.Error(), "rule #1") {
+				t.Errorf("Expected invalid rule error got %v", err)
+			}
+		})
+	}
+}
+
+func TestInjectManifestsOwnerKinds(t *testing.T) {
+	var config WebhookConfig
+	if err := yaml.Unmarshal([]byte("policy: disabled\nrules:\n- owner-kinds: [ReplicaSet]\n  policy: enabled\n"), &config); err != nil {
+		t.Fatal(err)
+	}
+	manifests := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    metadata:
+      annotations:
+        config.scccmd.github.com/destination: config.yaml
+    spec:
+      containers:
+      - name: app
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  template:
+    metadata:
+      annotations:
+        config.scccmd.github.com/destination: config.yaml
+    spec:
+      containers:
+      - name: db
+`
+	var out bytes.Buffer
+	if err := InjectManifests(strings.NewReader(manifests), &out, "default", &config); err != nil {
+		t.Fatalf("InjectManifests() failed: %v", err)
+	}
+	docs := strings.Split(out.String(), "\n"+documentSeparator)
+	if len(docs) != 2 {
+		t.Fatalf("Expected 2 documents got %s", out.String())
+	}
+	if !strings.Contains(docs[0], "config-init") {
+		t.Errorf("Expected the pods of the Deployment owned by ReplicaSet injected got %s", docs[0])
+	}
+	if strings.Contains(docs[1], "config-init") {
+		t.Errorf("Expected the StatefulSet not injected got %s", docs[1])
+	}
+	if strings.Contains(out.String(), "ownerReferences") {
+		t.Errorf("Expected no owner references written got %s", out.String())
+	}
+}
@@ -0,0 +1,201 @@
+// Package metrics contains adapters exposing the client metrics to monitoring systems.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets upper bounds of the request duration histogram buckets in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	endpoint client.Endpoint
+	result   string
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Prometheus collects the client metrics and exposes them in the Prometheus text format,
+// it is either scraped as http.Handler or pushed to the Pushgateway.
+type Prometheus struct {
+	namespace string
+	buckets   []float64
+
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[client.Endpoint]*histogram
+	retries   map[client.Endpoint]uint64
+	bytes     map[client.Endpoint]int64
+}
+
+// NewPrometheus creates the collector with metric names prefixed by the namespace, e.g. 'scccmd'.
+func NewPrometheus(namespace string) *Prometheus {
+	return &Prometheus{
+		namespace: namespace,
+		buckets:   DefaultBuckets,
+		requests:  map[requestKey]uint64{},
+		durations: map[client.Endpoint]*histogram{},
+		retries:   map[client.Endpoint]uint64{},
+		bytes:     map[client.Endpoint]int64{},
+	}
+}
+
+// ObserveRequest observes the finished operation.
+func (p *Prometheus) ObserveRequest(endpoint client.Endpoint, result string, duration time.Duration, bytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requests[requestKey{endpoint, result}]++
+	p.bytes[endpoint] += bytes
+
+	h, ok := p.durations[endpoint]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(p.buckets))}
+		p.durations[endpoint] = h
+	}
+	seconds := duration.Seconds()
+	for i, upper := range p.buckets {
+		if seconds <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// ObserveRetry observes the retried request.
+func (p *Prometheus) ObserveRetry(endpoint client.Endpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.retries[endpoint]++
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var buf bytes.Buffer
+
+	name := p.name("requests_total")
+	fmt.Fprintf(&buf, "# HELP %s Total number of config server requests by endpoint and result.\n# TYPE %s counter\n", name, name)
+	keys := make([]requestKey, 0, len(p.requests))
+	for key := range p.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].result < keys[j].result
+	})
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%s{endpoint=%q,result=%q} %d\n", name, key.endpoint, key.result, p.requests[key])
+	}
+
+	name = p.name("request_duration_seconds")
+	fmt.Fprintf(&buf, "# HELP %s Duration of config server requests including retries.\n# TYPE %s histogram\n", name, name)
+	for _, endpoint := range sortedEndpoints(p.durations) {
+		h := p.durations[endpoint]
+		for i, upper := range p.buckets {
+			fmt.Fprintf(&buf, "%s_bucket{endpoint=%q,le=%q} %d\n", name, endpoint, formatFloat(upper), h.counts[i])
+		}
+		fmt.Fprintf(&buf, "%s_bucket{endpoint=%q,le=\"+Inf\"} %d\n", name, endpoint, h.count)
+		fmt.Fprintf(&buf, "%s_sum{endpoint=%q} %s\n", name, endpoint, formatFloat(h.sum))
+		fmt.Fprintf(&buf, "%s_count{endpoint=%q} %d\n", name, endpoint, h.count)
+	}
+
+	name = p.name("retries_total")
+	fmt.Fprintf(&buf, "# HELP %s Total number of retried config server requests.\n# TYPE %s counter\n", name, name)
+	for _, endpoint := range sortedEndpoints(p.retries) {
+		fmt.Fprintf(&buf, "%s{endpoint=%q} %d\n", name, endpoint, p.retries[endpoint])
+	}
+
+	name = p.name("response_bytes_total")
+	fmt.Fprintf(&buf, "# HELP %s Total number of bytes received from the config server.\n# TYPE %s counter\n", name, name)
+	for _, endpoint := range sortedEndpoints(p.bytes) {
+		fmt.Fprintf(&buf, "%s{endpoint=%q} %d\n", name, endpoint, p.bytes[endpoint])
+	}
+
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP serves the metrics for scraping.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_, _ = p.WriteTo(w) // #nosec G104
+}
+
+// Push pushes the metrics to the Pushgateway under the job and instance grouping key, replacing the previously
+// pushed metrics of the same job and instance only. Empty instance groups the metrics by the job only.
+func (p *Prometheus) Push(ctx context.Context, gateway string, job string, instance string) error {
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	uri := strings.TrimRight(gateway, "/") + "/metrics/job/" + url.PathEscape(job)
+	if instance != "" {
+		uri += "/instance/" + url.PathEscape(instance)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, uri, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024)) // #nosec G104
+		return fmt.Errorf("unexpected response %d from %s: %s", resp.StatusCode, uri, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (p *Prometheus) name(name string) string {
+	if p.namespace == "" {
+		return "client_" + name
+	}
+	return p.namespace + "_client_" + name
+}
+
+func sortedEndpoints[V any](m map[client.Endpoint]V) []client.Endpoint {
+	endpoints := make([]client.Endpoint, 0, len(m))
+	for endpoint := range m {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i] < endpoints[j] })
+	return endpoints
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+const expectedMetrics = `# HELP scccmd_client_requests_total Total number of config server requests by endpoint and result.
+# TYPE scccmd_client_requests_total counter
+scccmd_client_requests_total{endpoint="file",result="not_found"} 1
+scccmd_client_requests_total{endpoint="file",result="ok"} 2
+# HELP scccmd_client_request_duration_seconds Duration of config server requests including retries.
+# TYPE scccmd_client_request_duration_seconds histogram
+scccmd_client_request_duration_seconds_bucket{endpoint="file",le="0.5"} 2
+scccmd_client_request_duration_seconds_bucket{endpoint="file",le="1"} 2
+scccmd_client_request_duration_seconds_bucket{endpoint="file",le="+Inf"} 3
+scccmd_client_request_duration_seconds_sum{endpoint="file"} 2.5
+scccmd_client_request_duration_seconds_count{endpoint="file"} 3
+# HELP scccmd_client_retries_total Total number of retried config server requests.
+# TYPE scccmd_client_retries_total counter
+scccmd_client_retries_total{endpoint="encrypt"} 1
+# HELP scccmd_client_response_bytes_total Total number of bytes received from the config server.
+# TYPE scccmd_client_response_bytes_total counter
+scccmd_client_response_bytes_total{endpoint="file"} 300
+`
+
+func testPrometheus() *Prometheus {
+	p := NewPrometheus("scccmd")
+	p.buckets = []float64{.5, 1}
+	p.ObserveRequest(client.EndpointFile, client.ResultOK, 250*time.Millisecond, 100)
+	p.ObserveRequest(client.EndpointFile, client.ResultOK, 250*time.Millisecond, 200)
+	p.ObserveRequest(client.EndpointFile, client.ResultNotFound, 2*time.Second, 0)
+	p.ObserveRetry(client.EndpointEncrypt)
+	return p
+}
+
+func TestPrometheus_WriteTo(t *testing.T) {
+	var buf strings.Builder
+	if _, err := testPrometheus().WriteTo(&buf); err != nil {
+		t.Fatal("WriteTo failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect metrics", expectedMetrics, buf.String())
+}
+
+func TestPrometheus_ServeHTTP(t *testing.T) {
+	w := httptest.NewRecorder()
+	testPrometheus().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	testutil.AssertString(t, "Incorrect content type", contentType, w.Header().Get("Content-Type"))
+	testutil.AssertString(t, "Incorrect metrics", expectedMetrics, w.Body.String())
+}
+
+func TestPrometheus_Push(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testutil.AssertString(t, "Incorrect method", http.MethodPut, r.Method)
+		testutil.AssertString(t, "Incorrect path", "/metrics/job/scccmd/instance/app-0", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		testutil.AssertString(t, "Incorrect metrics", expectedMetrics, string(body))
+	}))
+	defer ts.Close()
+
+	if err := testPrometheus().Push(context.Background(), ts.URL+"/", "scccmd", "app-0"); err != nil {
+		t.Error("Push failed with: ", err)
+	}
+
+	if err := NewPrometheus("").Push(context.Background(), "http://localhost:0", "scccmd", ""); err == nil {
+		t.Error("Push to unavailable gateway should have failed")
+	}
+}
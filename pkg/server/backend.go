@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoSuchLabel returned by the Backend if the label does not exist.
+var ErrNoSuchLabel = errors.New("no such label")
+
+// Backend provides the content of the config repository.
+type Backend interface {
+	// Snapshot returns the content of the config repository at the label, empty label means the default label
+	Snapshot(ctx context.Context, label string) (*Snapshot, error)
+}
+
+// Snapshot content of the config repository at single label.
+type Snapshot struct {
+	// FS content of the repository
+	FS fs.FS
+
+	// Location prefix of the property source names, e.g. 'file:/config/'
+	Location string
+
+	// SearchPaths directories of the config files, the first one has the highest precedence,
+	// paths might contain '{application}', '{profile}' and '{label}' placeholders, empty means the root
+	SearchPaths []string
+
+	// Version of the content, e.g. commit SHA, empty if the repository is not versioned
+	Version string
+}
+
+type nativeBackend struct {
+	dir         string
+	searchPaths []string
+}
+
+// NewNativeBackend creates the Backend serving the files from the directory, the same as Spring Cloud Config Server
+// 'native' profile. The label subdirectory of every search path takes precedence over the search path itself,
+// unless the search path contains the '{label}' placeholder.
+func NewNativeBackend(dir string, searchPaths ...string) Backend {
+	if len(searchPaths) == 0 {
+		searchPaths = []string{""}
+	}
+	return &nativeBackend{dir: dir, searchPaths: searchPaths}
+}
+
+// Snapshot returns the content of the directory.
+func (b *nativeBackend) Snapshot(_ context.Context, label string) (*Snapshot, error) {
+	info, err := os.Stat(b.dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: b.dir, Err: errors.New("not a directory")}
+	}
+
+	var searchPaths []string
+	for _, p := range b.searchPaths {
+		if label != "" && !strings.Contains(p, labelPlaceholder) {
+			searchPaths = append(searchPaths, path.Join(p, labelPlaceholder))
+		}
+		searchPaths = append(searchPaths, p)
+	}
+
+	location, err := filepath.Abs(b.dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{
+		FS:          os.DirFS(b.dir),
+		Location:    "file:" + filepath.ToSlash(location) + "/",
+		SearchPaths: searchPaths,
+	}, nil
+}
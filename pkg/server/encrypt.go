@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1" // #nosec G505 -- required for compatibility with Spring Security
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// DefaultSalt salt used by the Spring Cloud Config Server if 'encrypt.salt' is not set.
+const DefaultSalt = "deadbeef"
+
+const (
+	keyIterations = 1024
+	keyLength     = 32
+)
+
+// ErrInvalidCipher returned when the value is not encrypted with the key.
+var ErrInvalidCipher = errors.New("text not encrypted with this key")
+
+// Encryptor encrypts and decrypts the values of the '/encrypt' and '/decrypt' endpoints and the '{cipher}' properties.
+type Encryptor interface {
+	Encrypt(value string) (string, error)
+	Decrypt(value string) (string, error)
+}
+
+type textEncryptor struct {
+	block cipher.Block
+}
+
+// NewTextEncryptor creates the symmetric Encryptor compatible with the Spring Cloud Config Server 'encrypt.key',
+// values are AES-256-CBC encrypted with the key derived by PBKDF2 from the key and the hex encoded salt.
+func NewTextEncryptor(key string, salt string) (Encryptor, error) {
+	s, err := hex.DecodeString(salt)
+	if err != nil {
+		return nil, errors.New("salt must be hex encoded")
+	}
+
+	derived, err := pbkdf2.Key(sha1.New, key, s, keyIterations, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return &textEncryptor{block: block}, nil
+}
+
+// Encrypt encrypts the value with the random IV, the result is hex encoded IV followed by the cipher text.
+func (e *textEncryptor) Encrypt(value string) (string, error) {
+	size := e.block.BlockSize()
+	padding := size - len(value)%size
+	plain := append([]byte(value), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	out := make([]byte, size+len(plain))
+	iv := out[:size]
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return "", err
+	}
+	cipher.NewCBCEncrypter(e.block, iv).CryptBlocks(out[size:], plain)
+	return hex.EncodeToString(out), nil
+}
+
+// Decrypt decrypts the value produced by Encrypt.
+func (e *textEncryptor) Decrypt(value string) (string, error) {
+	size := e.block.BlockSize()
+	data, err := hex.DecodeString(value)
+	if err != nil || len(data) < 2*size || len(data)%size != 0 {
+		return "", ErrInvalidCipher
+	}
+
+	plain := make([]byte, len(data)-size)
+	cipher.NewCBCDecrypter(e.block, data[:size]).CryptBlocks(plain, data[size:])
+
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > size || !bytes.Equal(plain[len(plain)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return "", ErrInvalidCipher
+	}
+	return string(plain[:len(plain)-padding]), nil
+}
@@ -0,0 +1,537 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wandera/scccmd/pkg/client"
+	"github.com/wandera/scccmd/pkg/config"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	applicationPlaceholder = "{application}"
+	profilePlaceholder     = "{profile}"
+	labelPlaceholder       = "{label}"
+
+	// defaultApplication name of the config shared by all the applications
+	defaultApplication = "application"
+
+	cipherPrefix   = "{cipher}"
+	invalidPrefix  = "invalid."
+	invalidValue   = "<n/a>"
+	placeholderKey = "${"
+)
+
+// extensions of the config files, the first one has the highest precedence.
+var extensions = []string{"properties", "yml", "yaml"}
+
+// activationKeys keys of the YAML document activating it only for the listed profiles.
+var activationKeys = []string{"spring.config.activate.on-profile", "spring.profiles"}
+
+// loadEnvironment loads the property sources of the applications and profiles from the snapshot,
+// the first property source has the highest precedence.
+func loadEnvironment(s *Snapshot, applications []string, profiles []string, label string) (*client.Environment, error) {
+	env := &client.Environment{
+		Name:            strings.Join(applications, ","),
+		Profiles:        profiles,
+		Label:           label,
+		Version:         s.Version,
+		PropertySources: []client.PropertySource{},
+	}
+
+	dirs := searchDirs(s.SearchPaths, applications, profiles, label)
+	for _, name := range configNames(applications, profiles) {
+		for _, dir := range dirs {
+			for _, ext := range extensions {
+				file := path.Join(dir, name+"."+ext)
+				data, err := readFile(s.FS, file)
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+
+				source, err := parseConfig(data, ext, profiles)
+				if err != nil {
+					return nil, fmt.Errorf("unable to parse %s: %v", file, err)
+				}
+				env.PropertySources = append(env.PropertySources, client.PropertySource{
+					Name:   s.Location + file,
+					Source: source,
+				})
+			}
+		}
+	}
+	return env, nil
+}
+
+// findResource finds the plain text resource, the profile specific variant e.g. 'nginx-prod.conf'
+// takes precedence over 'nginx.conf'.
+func findResource(s *Snapshot, applications []string, profiles []string, label string, name string) ([]byte, error) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	var candidates []string
+	for i := len(profiles) - 1; i >= 0; i-- {
+		candidates = append(candidates, base+"-"+profiles[i]+ext)
+	}
+	candidates = append(candidates, name)
+
+	dirs := searchDirs(s.SearchPaths, applications, profiles, label)
+	for _, candidate := range candidates {
+		for _, dir := range dirs {
+			data, err := readFile(s.FS, path.Join(dir, candidate))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return data, err
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// readFile reads the file, invalid paths and directories are reported as not existing.
+func readFile(fsys fs.FS, name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrNotExist
+	}
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fs.ErrNotExist
+	}
+	return fs.ReadFile(fsys, name)
+}
+
+// configNames names of the config files without extension, the first one has the highest precedence.
+func configNames(applications []string, profiles []string) []string {
+	var names []string
+	for i := len(profiles) - 1; i >= 0; i-- {
+		for j := len(applications) - 1; j >= 0; j-- {
+			if applications[j] != defaultApplication {
+				names = append(names, applications[j]+"-"+profiles[i])
+			}
+		}
+		names = append(names, defaultApplication+"-"+profiles[i])
+	}
+	for j := len(applications) - 1; j >= 0; j-- {
+		if applications[j] != defaultApplication {
+			names = append(names, applications[j])
+		}
+	}
+	return append(names, defaultApplication)
+}
+
+// searchDirs expands the placeholders in the search paths, duplicates are removed.
+func searchDirs(searchPaths []string, applications []string, profiles []string, label string) []string {
+	if len(searchPaths) == 0 {
+		searchPaths = []string{""}
+	}
+
+	var dirs []string
+	seen := map[string]bool{}
+	for _, p := range searchPaths {
+		expanded := []string{strings.ReplaceAll(p, labelPlaceholder, label)}
+		expanded = expand(expanded, applicationPlaceholder, applications)
+		expanded = expand(expanded, profilePlaceholder, profiles)
+		for _, dir := range expanded {
+			dir = path.Clean("/" + dir)[1:]
+			if dir == "" {
+				dir = "."
+			}
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// expand replaces the placeholder by every value, the last value has the highest precedence so it is first.
+func expand(paths []string, placeholder string, values []string) []string {
+	var res []string
+	for _, p := range paths {
+		if !strings.Contains(p, placeholder) {
+			res = append(res, p)
+			continue
+		}
+		for i := len(values) - 1; i >= 0; i-- {
+			res = append(res, strings.ReplaceAll(p, placeholder, values[i]))
+		}
+	}
+	return res
+}
+
+// parseConfig parses the config file into flattened properties.
+func parseConfig(data []byte, ext string, profiles []string) (map[string]interface{}, error) {
+	if ext == "properties" {
+		return parseProperties(data)
+	}
+
+	source := map[string]interface{}{}
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc interface{}
+		err := d.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return source, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		properties := map[string]interface{}{}
+		flatten("", doc, properties)
+		if !activeDocument(properties, profiles) {
+			continue
+		}
+		for key, value := range properties {
+			source[key] = value
+		}
+	}
+}
+
+// activeDocument checks the document activation, e.g. 'spring.config.activate.on-profile: prod,!test'.
+func activeDocument(properties map[string]interface{}, profiles []string) bool {
+	for _, key := range activationKeys {
+		value, ok := properties[key]
+		if !ok {
+			continue
+		}
+		for _, expr := range client.SplitList(fmt.Sprint(value)) {
+			negated := strings.HasPrefix(expr, "!")
+			if contains(profiles, strings.TrimPrefix(expr, "!")) != negated {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// flatten collects the values of the YAML tree into properties with flattened keys e.g. 'a.b[0].c'.
+func flatten(prefix string, node interface{}, properties map[string]interface{}) {
+	switch n := node.(type) {
+	case map[interface{}]interface{}:
+		for k, v := range n {
+			flatten(joinKey(prefix, fmt.Sprint(k)), v, properties)
+		}
+	case []interface{}:
+		for i, v := range n {
+			flatten(prefix+"["+strconv.Itoa(i)+"]", v, properties)
+		}
+	case nil:
+		if prefix != "" {
+			properties[prefix] = ""
+		}
+	default:
+		properties[prefix] = n
+	}
+}
+
+func joinKey(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// parseProperties parses the Java properties file.
+func parseProperties(data []byte) (map[string]interface{}, error) {
+	properties := map[string]interface{}{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var logical strings.Builder
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if logical.Len() == 0 && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+
+		// odd number of trailing backslashes continues the line
+		trailing := len(line) - len(strings.TrimRight(line, `\`))
+		if trailing%2 == 1 {
+			logical.WriteString(line[:len(line)-1])
+			continue
+		}
+		logical.WriteString(line)
+
+		key, value := splitProperty(logical.String())
+		properties[key] = value
+		logical.Reset()
+	}
+	if logical.Len() > 0 {
+		key, value := splitProperty(logical.String())
+		properties[key] = value
+	}
+	return properties, scanner.Err()
+}
+
+// splitProperty splits the logical properties line into the unescaped key and value.
+func splitProperty(line string) (string, string) {
+	i := 0
+	for ; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' {
+			i++
+			continue
+		}
+		if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+			break
+		}
+	}
+	key := line[:min(i, len(line))]
+
+	rest := strings.TrimLeft(line[min(i, len(line)):], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	return unescape(key), unescape(rest)
+}
+
+// unescape replaces the properties escape sequences including '\uXXXX'.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i == len(s)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// decryptEnvironment decrypts the '{cipher}' values in place, the values which cannot be decrypted
+// are replaced by 'invalid.<key>: <n/a>' the same as Spring Cloud Config Server does.
+func decryptEnvironment(env *client.Environment, e Encryptor) {
+	for _, ps := range env.PropertySources {
+		for key, value := range ps.Source {
+			s, ok := value.(string)
+			if !ok || !strings.HasPrefix(s, cipherPrefix) {
+				continue
+			}
+
+			plain, err := e.Decrypt(strings.TrimPrefix(s, cipherPrefix))
+			if err != nil {
+				delete(ps.Source, key)
+				ps.Source[invalidPrefix+key] = invalidValue
+				continue
+			}
+			ps.Source[key] = plain
+		}
+	}
+}
+
+// mergeEnvironment merges the property sources into single properties with resolved placeholders,
+// unresolvable placeholders are kept as they are.
+func mergeEnvironment(env *client.Environment) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for i := len(env.PropertySources) - 1; i >= 0; i-- {
+		for key, value := range env.PropertySources[i].Source {
+			merged[key] = value
+		}
+	}
+
+	r := config.NewResolver(config.EnvironmentLookup(env))
+	for key, value := range merged {
+		if s, ok := value.(string); ok && strings.Contains(s, placeholderKey) {
+			if resolved, err := r.Resolve(s); err == nil {
+				merged[key] = resolved
+			}
+		}
+	}
+	return merged
+}
+
+// resolveText resolves the placeholders in the text line by line, lines with unresolvable placeholders are kept.
+func resolveText(data []byte, env *client.Environment) []byte {
+	if !bytes.Contains(data, []byte(placeholderKey)) {
+		return data
+	}
+
+	r := config.NewResolver(config.EnvironmentLookup(env))
+	lines := strings.SplitAfter(string(data), "\n")
+	for i, line := range lines {
+		if strings.Contains(line, placeholderKey) {
+			if resolved, err := r.Resolve(line); err == nil {
+				lines[i] = resolved
+			}
+		}
+	}
+	return []byte(strings.Join(lines, ""))
+}
+
+// formatProperties formats the properties as sorted 'key: value' lines.
+func formatProperties(properties map[string]interface{}) []byte {
+	var b bytes.Buffer
+	for _, key := range sortedKeys(properties) {
+		fmt.Fprintf(&b, "%s: %v\n", key, properties[key])
+	}
+	return b.Bytes()
+}
+
+// formatYAML formats the properties as the YAML tree.
+func formatYAML(properties map[string]interface{}) ([]byte, error) {
+	return yaml.Marshal(toMapSlice(tree(properties)))
+}
+
+// tree expands the flattened keys e.g. 'a.b[0].c' into the tree of maps and slices.
+func tree(properties map[string]interface{}) map[string]interface{} {
+	root := map[string]interface{}{}
+	for _, key := range sortedKeys(properties) {
+		parts := splitKey(key)
+		if len(parts) == 0 || parts[0].index >= 0 {
+			root[key] = properties[key]
+			continue
+		}
+		insert(root, parts, properties[key])
+	}
+	return root
+}
+
+// insert sets the value at the path of the key parts in the node and returns the updated node.
+func insert(node interface{}, parts []keyPart, value interface{}) interface{} {
+	if len(parts) == 0 {
+		return value
+	}
+
+	part := parts[0]
+	if part.index < 0 {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			m = map[string]interface{}{}
+		}
+		m[part.name] = insert(m[part.name], parts[1:], value)
+		return m
+	}
+
+	l, _ := node.([]interface{})
+	for len(l) <= part.index {
+		l = append(l, nil)
+	}
+	l[part.index] = insert(l[part.index], parts[1:], value)
+	return l
+}
+
+// toMapSlice converts the maps of the tree to sorted yaml.MapSlice.
+func toMapSlice(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		res := make(yaml.MapSlice, 0, len(n))
+		for _, key := range sortedKeys(n) {
+			res = append(res, yaml.MapItem{Key: key, Value: toMapSlice(n[key])})
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(n))
+		for i, item := range n {
+			res[i] = toMapSlice(item)
+		}
+		return res
+	default:
+		return node
+	}
+}
+
+// keyPart single part of the flattened key, either the name or the index.
+type keyPart struct {
+	name  string
+	index int
+}
+
+// maxIndex the highest index expanded into the list, higher indexes are kept as map keys.
+const maxIndex = 1 << 16
+
+// splitKey splits the flattened key e.g. 'a.b[0].c' or 'a[b.c]' into parts.
+func splitKey(key string) []keyPart {
+	var parts []keyPart
+	var name strings.Builder
+	flush := func() {
+		if name.Len() > 0 {
+			parts = append(parts, keyPart{name: name.String(), index: -1})
+			name.Reset()
+		}
+	}
+
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		end := strings.IndexByte(key[i:], ']')
+		switch {
+		case c == '.':
+			flush()
+		case c == '[' && end > 0:
+			flush()
+			inner := key[i+1 : i+end]
+			if index, err := strconv.Atoi(inner); err == nil && index >= 0 && index <= maxIndex {
+				parts = append(parts, keyPart{index: index})
+			} else {
+				parts = append(parts, keyPart{name: inner, index: -1})
+			}
+			i += end
+		default:
+			name.WriteByte(c)
+		}
+	}
+	flush()
+	return parts
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
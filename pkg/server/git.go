@@ -0,0 +1,266 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCachedTrees number of the commit trees kept in memory by the git Backend.
+const maxCachedTrees = 32
+
+type gitBackend struct {
+	repo        string
+	searchPaths []string
+
+	once     sync.Once
+	location string
+
+	mu    sync.Mutex
+	trees map[string]*gitFS
+}
+
+// NewGitBackend creates the Backend serving the committed content of the local git repository, the same as
+// Spring Cloud Config Server 'git' profile. Labels are branches, tags or commits, remote branches of the clone
+// are used if the local branch does not exist. Empty label means HEAD. The Environment version is the commit SHA.
+// The search paths might contain '{application}', '{profile}' and '{label}' placeholders.
+func NewGitBackend(repo string, searchPaths ...string) Backend {
+	return &gitBackend{
+		repo:        repo,
+		searchPaths: searchPaths,
+		trees:       map[string]*gitFS{},
+	}
+}
+
+// defaultLabel empty label resolved to HEAD, the repository might not have the master branch.
+func (b *gitBackend) defaultLabel() string {
+	return ""
+}
+
+// Snapshot returns the content of the commit the label points to.
+func (b *gitBackend) Snapshot(ctx context.Context, label string) (*Snapshot, error) {
+	sha, err := b.resolve(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := b.tree(ctx, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	b.once.Do(func() {
+		b.location = b.remoteLocation(ctx)
+	})
+	return &Snapshot{
+		FS:          tree,
+		Location:    b.location,
+		SearchPaths: b.searchPaths,
+		Version:     sha,
+	}, nil
+}
+
+// resolve resolves the label to the commit SHA.
+func (b *gitBackend) resolve(ctx context.Context, label string) (string, error) {
+	if label == "" {
+		label = "HEAD"
+	}
+	if strings.HasPrefix(label, "-") {
+		return "", fmt.Errorf("%w: %s", ErrNoSuchLabel, label)
+	}
+
+	for _, rev := range []string{label, "origin/" + label} {
+		out, err := b.git(ctx, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+		if err == nil {
+			return strings.TrimSpace(string(out)), nil
+		}
+		// unknown revision exits with 1, other failures e.g. not a repository with 128
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrNoSuchLabel, label)
+}
+
+// tree lists the files of the commit, the listing is cached as the commits never change.
+func (b *gitBackend) tree(ctx context.Context, sha string) (*gitFS, error) {
+	b.mu.Lock()
+	tree, ok := b.trees[sha]
+	b.mu.Unlock()
+	if ok {
+		return tree, nil
+	}
+
+	out, err := b.git(ctx, "ls-tree", "-r", "-z", "--full-tree", sha)
+	if err != nil {
+		return nil, err
+	}
+
+	tree = &gitFS{backend: b, files: map[string]string{}, dirs: map[string]bool{".": true}}
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		// <mode> SP <type> SP <object> TAB <path>
+		meta, name, ok := strings.Cut(string(entry), "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || fields[1] != "blob" || fields[0] == "120000" {
+			continue
+		}
+		tree.files[name] = fields[2]
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			tree.dirs[dir] = true
+		}
+	}
+
+	b.mu.Lock()
+	if len(b.trees) >= maxCachedTrees {
+		b.trees = map[string]*gitFS{}
+	}
+	b.trees[sha] = tree
+	b.mu.Unlock()
+	return tree, nil
+}
+
+// remoteLocation prefix of the property source names, the origin URL without credentials if the repository is a clone.
+func (b *gitBackend) remoteLocation(ctx context.Context) string {
+	if out, err := b.git(ctx, "config", "--get", "remote.origin.url"); err == nil {
+		if origin := strings.TrimSpace(string(out)); origin != "" {
+			if u, err := url.Parse(origin); err == nil && u.User != nil {
+				u.User = nil
+				origin = u.String()
+			}
+			return strings.TrimSuffix(origin, "/") + "/"
+		}
+	}
+	location, err := filepath.Abs(b.repo)
+	if err != nil {
+		location = b.repo
+	}
+	return "file:" + filepath.ToSlash(location) + "/"
+}
+
+func (b *gitBackend) git(ctx context.Context, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", b.repo}, args...)...) // #nosec G204
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return nil, &gitError{args: args, stderr: strings.TrimSpace(stderr.String()), err: err}
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// gitError failed git command with its error output.
+type gitError struct {
+	args   []string
+	stderr string
+	err    error
+}
+
+func (e *gitError) Error() string {
+	return fmt.Sprintf("git %s: %s", strings.Join(e.args, " "), e.stderr)
+}
+
+func (e *gitError) Unwrap() error {
+	return e.err
+}
+
+// gitFS read-only fs.FS of single commit, the file content is read on demand.
+type gitFS struct {
+	backend *gitBackend
+	files   map[string]string
+	dirs    map[string]bool
+}
+
+// Open opens the file or directory of the commit.
+func (f *gitFS) Open(name string) (fs.File, error) {
+	info, err := f.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &gitFile{info: info}, nil
+	}
+
+	data, err := f.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return &gitFile{info: info, Reader: bytes.NewReader(data)}, nil
+}
+
+// Stat describes the file or directory of the commit.
+func (f *gitFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if f.dirs[name] {
+		return gitFileInfo{name: path.Base(name), dir: true}, nil
+	}
+	if _, ok := f.files[name]; ok {
+		return gitFileInfo{name: path.Base(name)}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadFile reads the content of the file of the commit.
+func (f *gitFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	object, ok := f.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return f.backend.git(context.Background(), "cat-file", "blob", object)
+}
+
+type gitFile struct {
+	info fs.FileInfo
+	*bytes.Reader
+}
+
+func (f *gitFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *gitFile) Read(p []byte) (int, error) {
+	if f.Reader == nil {
+		return 0, &fs.PathError{Op: "read", Path: f.info.Name(), Err: errors.New("is a directory")}
+	}
+	return f.Reader.Read(p)
+}
+
+func (f *gitFile) Close() error {
+	return nil
+}
+
+type gitFileInfo struct {
+	name string
+	dir  bool
+}
+
+func (i gitFileInfo) Name() string       { return i.name }
+func (i gitFileInfo) Size() int64        { return 0 }
+func (i gitFileInfo) ModTime() time.Time { return time.Time{} }
+func (i gitFileInfo) IsDir() bool        { return i.dir }
+func (i gitFileInfo) Sys() interface{}   { return nil }
+
+func (i gitFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
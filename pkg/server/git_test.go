@@ -0,0 +1,169 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com",
+		"-c", "commit.gpgsign=false", "-c", "init.defaultBranch=master"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed with: %v %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func testRepository(t *testing.T) (string, map[string]string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	writeFiles(t, dir, map[string]string{
+		"application.yml":   "name: default\n",
+		"app/app.yml":       "name: app\nurl: http://${host:localhost}\n",
+		"app/app-prod.yml":  "name: app-prod\n",
+		"other/app.yml":     "name: other\n",
+		"app/nginx.conf":    "server ${name};\n",
+		"app/nginx-old.txt": "old\n",
+	})
+	git(t, dir, "add", "-A")
+	git(t, dir, "commit", "-q", "-m", "initial")
+	shas := map[string]string{"initial": git(t, dir, "rev-parse", "HEAD")}
+	git(t, dir, "tag", "v1")
+
+	git(t, dir, "checkout", "-q", "-b", "feature/foo")
+	writeFiles(t, dir, map[string]string{"app/app.yml": "name: app-feature\n"})
+	git(t, dir, "commit", "-q", "-am", "feature")
+	shas["feature"] = git(t, dir, "rev-parse", "HEAD")
+	git(t, dir, "checkout", "-q", "master")
+
+	// uncommitted changes are not served
+	writeFiles(t, dir, map[string]string{"app/app.yml": "name: dirty\n"})
+	return dir, shas
+}
+
+func TestGitBackend(t *testing.T) {
+	dir, shas := testRepository(t)
+	ts := httptest.NewServer(New(NewGitBackend(dir, "{application}")))
+	defer ts.Close()
+
+	tests := []struct {
+		label   string
+		version string
+		name    string
+	}{
+		{"", shas["initial"], "app"},
+		{"master", shas["initial"], "app"},
+		{"v1", shas["initial"], "app"},
+		{"feature/foo", shas["feature"], "app-feature"},
+		{shas["feature"][:10], shas["feature"], "app-feature"},
+	}
+
+	for _, tt := range tests {
+		env, err := newTestClient(ts.URL, "default", tt.label).FetchEnvironment()
+		if err != nil {
+			t.Fatalf("FetchEnvironment of %s failed with: %v", tt.label, err)
+		}
+		testutil.AssertString(t, "Incorrect version of "+tt.label, tt.version, env.Version)
+		testutil.AssertString(t, "Incorrect name of "+tt.label, tt.name, env.PropertySources[0].Source["name"].(string))
+		testutil.AssertString(t, "Incorrect source name", "file:"+filepath.ToSlash(dir)+"/app/app.yml", env.PropertySources[0].Name)
+	}
+
+	_, err := newTestClient(ts.URL, "default", "missing").FetchEnvironment()
+	if !errors.Is(err, client.ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel got %v instead", err)
+	}
+	_, err = newTestClient(ts.URL, "default", "--help").FetchEnvironment()
+	if !errors.Is(err, client.ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel for option like label got %v instead", err)
+	}
+}
+
+func TestGitBackend_DefaultBranch(t *testing.T) {
+	dir, shas := testRepository(t)
+	git(t, dir, "branch", "-q", "-m", "master", "main")
+	ts := httptest.NewServer(New(NewGitBackend(dir, "{application}")))
+	defer ts.Close()
+
+	env, err := newTestClient(ts.URL, "default", "").FetchEnvironment()
+	if err != nil {
+		t.Fatal("FetchEnvironment without label failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect version of HEAD", shas["initial"], env.Version)
+
+	resp, err := http.Get(ts.URL + "/app/default/nginx.conf?useDefaultLabel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected resource of the default label served got %s instead", resp.Status)
+	}
+}
+
+func TestGitBackend_Resources(t *testing.T) {
+	dir, _ := testRepository(t)
+	ts := httptest.NewServer(New(NewGitBackend(dir, "{application}")))
+	defer ts.Close()
+
+	c := client.NewClient(client.Config{URI: ts.URL, Applications: []string{"app"}, Profiles: []string{"prod"}, Label: "master"})
+	data, err := c.FetchFileE("nginx.conf")
+	if err != nil {
+		t.Fatal("FetchFileE failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect resource", "server app-prod;", string(data))
+
+	yml, err := c.FetchAsYAML()
+	if err != nil {
+		t.Fatal("FetchAsYAML failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect values", "name: app-prod\nurl: http://localhost", yml)
+
+	if _, err := c.FetchFileE("missing.conf"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound got %v instead", err)
+	}
+}
+
+func TestGitBackend_Clone(t *testing.T) {
+	origin, shas := testRepository(t)
+	dir := filepath.Join(t.TempDir(), "clone")
+	git(t, filepath.Dir(dir), "clone", "-q", "file://"+filepath.ToSlash(origin), dir)
+
+	ts := httptest.NewServer(New(NewGitBackend(dir, "{application}")))
+	defer ts.Close()
+
+	env, err := newTestClient(ts.URL, "default", "feature/foo").FetchEnvironment()
+	if err != nil {
+		t.Fatal("FetchEnvironment failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect version of remote branch", shas["feature"], env.Version)
+	testutil.AssertString(t, "Incorrect source name", "file://"+filepath.ToSlash(origin)+"/app/app.yml", env.PropertySources[0].Name)
+}
+
+func TestGitBackend_NotRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	ts := httptest.NewServer(New(NewGitBackend(t.TempDir())))
+	defer ts.Close()
+
+	_, err := newTestClient(ts.URL, "default", "master").FetchEnvironment()
+	if err == nil || errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected server error got %v instead", err)
+	}
+}
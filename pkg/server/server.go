@@ -0,0 +1,347 @@
+// Package server implements the Spring Cloud Config Server HTTP API, so the config repository can be served
+// without the JVM server e.g. in local development, CI or tests.
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+const (
+	// DefaultLabel label used if the request does not specify one.
+	DefaultLabel = "master"
+
+	encryptPath     = "/encrypt"
+	decryptPath     = "/decrypt"
+	healthPath      = "/actuator/health"
+	defaultLabelArg = "useDefaultLabel"
+
+	binaryContentType = "application/octet-stream"
+	maxCipherBodySize = 1024 * 1024
+	timestampFormat   = "2006-01-02T15:04:05.000-07:00"
+
+	// labelSlash Spring Cloud Config encoding of '/' in labels
+	labelSlash = "(_)"
+)
+
+// valuesPattern matches '{application}-{profile}.{ext}', the application is everything up to the last dash.
+var valuesPattern = regexp.MustCompile(`^(.+)-([^-]+)\.(yml|yaml|properties|json)$`)
+
+// Option customizes the Server created by New.
+type Option func(s *Server)
+
+// WithEncryptor enables the '/encrypt' and '/decrypt' endpoints and decryption of the '{cipher}' values.
+func WithEncryptor(encryptor Encryptor) Option {
+	return func(s *Server) {
+		s.encryptor = encryptor
+	}
+}
+
+// WithDefaultLabel sets the label used if the request does not specify one, DefaultLabel is used by default
+// and HEAD by the git backend.
+func WithDefaultLabel(label string) Option {
+	return func(s *Server) {
+		s.defaultLabel = label
+	}
+}
+
+// Server http.Handler serving the config from the Backend.
+type Server struct {
+	backend      Backend
+	encryptor    Encryptor
+	defaultLabel string
+}
+
+// errorResponse Spring Boot error response body.
+type errorResponse struct {
+	Timestamp string `json:"timestamp"`
+	Status    int    `json:"status"`
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	Path      string `json:"path"`
+}
+
+// defaultLabeler is implemented by the backends with their own label used if the request does not specify one.
+type defaultLabeler interface {
+	defaultLabel() string
+}
+
+// New creates the Server serving the config from the backend.
+func New(backend Backend, opts ...Option) *Server {
+	s := &Server{
+		backend:      backend,
+		defaultLabel: DefaultLabel,
+	}
+	if d, ok := backend.(defaultLabeler); ok {
+		s.defaultLabel = d.defaultLabel()
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ServeHTTP serves the Spring Cloud Config Server endpoints:
+//
+//	/{application}/{profile}[/{label}]             environment as JSON
+//	[/{label}]/{application}-{profile}.{ext}        merged properties as yml, yaml, properties or json
+//	/{application}/{profile}/{label}/{path}        plain text resource
+//	/{application}/{profile}/{path}?useDefaultLabel plain text resource from the default label
+//	/encrypt, /decrypt                             encryption of the values
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case encryptPath:
+		s.serveCipher(w, r, "encrypt")
+		return
+	case decryptPath:
+		s.serveCipher(w, r, "decrypt")
+		return
+	case healthPath:
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"UP"}`)) // #nosec G104
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, fmt.Sprintf("Request method '%s' is not supported", r.Method))
+		return
+	}
+
+	segments, err := splitPath(r.URL.EscapedPath())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, useDefaultLabel := r.URL.Query()[defaultLabelArg]
+	switch {
+	case len(segments) == 1 && valuesPattern.MatchString(segments[0]):
+		s.serveValues(w, r, "", segments[0])
+	case len(segments) == 2 && valuesPattern.MatchString(segments[1]):
+		s.serveValues(w, r, segments[0], segments[1])
+	case len(segments) >= 3 && useDefaultLabel:
+		s.serveResource(w, r, segments[0], segments[1], "", strings.Join(segments[2:], "/"))
+	case len(segments) == 2 || len(segments) == 3:
+		label := ""
+		if len(segments) == 3 {
+			label = segments[2]
+		}
+		s.serveEnvironment(w, r, segments[0], segments[1], label)
+	case len(segments) > 3:
+		s.serveResource(w, r, segments[0], segments[1], segments[2], strings.Join(segments[3:], "/"))
+	default:
+		writeError(w, r, http.StatusNotFound, "No endpoint "+r.URL.Path)
+	}
+}
+
+func (s *Server) serveEnvironment(w http.ResponseWriter, r *http.Request, application string, profile string, label string) {
+	env, err := s.environment(r, application, profile, label)
+	if err != nil {
+		writeBackendError(w, r, err)
+		return
+	}
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	serveContent(w, r, "application/json", data)
+}
+
+func (s *Server) serveValues(w http.ResponseWriter, r *http.Request, label string, name string) {
+	match := valuesPattern.FindStringSubmatch(name)
+	env, err := s.environment(r, match[1], match[2], label)
+	if err != nil {
+		writeBackendError(w, r, err)
+		return
+	}
+
+	properties := mergeEnvironment(env)
+	var data []byte
+	var contentType string
+	switch match[3] {
+	case "properties":
+		data, contentType = formatProperties(properties), "text/plain; charset=utf-8"
+	case "json":
+		data, err = json.Marshal(tree(properties))
+		contentType = "application/json"
+	default:
+		data, err = formatYAML(properties)
+		contentType = "text/plain; charset=utf-8"
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	serveContent(w, r, contentType, data)
+}
+
+func (s *Server) serveResource(w http.ResponseWriter, r *http.Request, application string, profile string, label string, name string) {
+	applications, profiles, label := s.parseRequest(application, profile, label)
+	snapshot, err := s.backend.Snapshot(r.Context(), label)
+	if err != nil {
+		writeBackendError(w, r, err)
+		return
+	}
+
+	data, err := findResource(snapshot, applications, profiles, label, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		writeError(w, r, http.StatusNotFound, "Not found: "+name)
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), binaryContentType) {
+		serveContent(w, r, binaryContentType, data)
+		return
+	}
+
+	env, err := loadEnvironment(snapshot, applications, profiles, label)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if s.encryptor != nil {
+		decryptEnvironment(env, s.encryptor)
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	serveContent(w, r, contentType, resolveText(data, env))
+}
+
+func (s *Server) serveCipher(w http.ResponseWriter, r *http.Request, operation string) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, fmt.Sprintf("Request method '%s' is not supported", r.Method))
+		return
+	}
+	if s.encryptor == nil {
+		writeError(w, r, http.StatusNotFound, "No key was installed for encryption service")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCipherBodySize))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	value := string(body)
+	// curl -d sends the body form encoded, the same as Spring the trailing '=' of such body is dropped
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = strings.TrimSuffix(unescaped, "=")
+		}
+	}
+
+	var res string
+	if operation == "encrypt" {
+		res, err = s.encryptor.Encrypt(value)
+	} else {
+		res, err = s.encryptor.Decrypt(strings.TrimPrefix(value, cipherPrefix))
+	}
+	if errors.Is(err, ErrInvalidCipher) {
+		writeError(w, r, http.StatusBadRequest, "Text not encrypted with this key")
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(res)) // #nosec G104
+}
+
+// environment loads the Environment of the request with the '{cipher}' values decrypted.
+func (s *Server) environment(r *http.Request, application string, profile string, label string) (*client.Environment, error) {
+	applications, profiles, label := s.parseRequest(application, profile, label)
+	snapshot, err := s.backend.Snapshot(r.Context(), label)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := loadEnvironment(snapshot, applications, profiles, label)
+	if err != nil {
+		return nil, err
+	}
+	if s.encryptor != nil {
+		decryptEnvironment(env, s.encryptor)
+	}
+	return env, nil
+}
+
+// parseRequest splits the comma separated applications and profiles and decodes the label.
+func (s *Server) parseRequest(application string, profile string, label string) ([]string, []string, string) {
+	if label == "" {
+		label = s.defaultLabel
+	}
+	return client.SplitList(application), client.SplitList(profile), strings.ReplaceAll(label, labelSlash, "/")
+}
+
+// splitPath splits the escaped path into unescaped segments.
+func splitPath(escaped string) ([]string, error) {
+	var segments []string
+	for _, segment := range strings.Split(strings.Trim(escaped, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, unescaped)
+	}
+	return segments, nil
+}
+
+// serveContent serves the content with the ETag, so the conditional requests of the cached clients are supported.
+func serveContent(w http.ResponseWriter, r *http.Request, contentType string, data []byte) {
+	sum := sha256.Sum256(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+func writeBackendError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrNoSuchLabel) {
+		writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	log.Errorf("Unable to load config for %s: %v", r.URL.Path, err)
+	writeError(w, r, http.StatusInternalServerError, err.Error())
+}
+
+// writeError writes the error in the same format as Spring Boot does.
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{ // #nosec G104
+		Timestamp: time.Now().Format(timestampFormat),
+		Status:    status,
+		Error:     http.StatusText(status),
+		Message:   message,
+		Path:      r.URL.Path,
+	})
+}
@@ -0,0 +1,342 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/wandera/scccmd/internal/testutil"
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	e, err := NewTextEncryptor("secret", DefaultSalt)
+	if err != nil {
+		t.Fatal("NewTextEncryptor failed with: ", err)
+	}
+	password, err := e.Encrypt("s3cr3t")
+	if err != nil {
+		t.Fatal("Encrypt failed with: ", err)
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"application.yml": "server:\n  port: 8080\n  hosts:\n    - a\n    - b\nlog: info\n",
+		"app.yml": "name: app\nurl: http://${server.hosts[0]}:${server.port}/${context:api}\nmissing: ${unknown}\n" +
+			"---\nspring.config.activate.on-profile: test\nname: app-test\n",
+		"app-prod.properties": "name = app-prod\nmulti=one,\\\n  two\npassword={cipher}" + password + "\nbroken={cipher}00\n",
+		"release/app.yml":     "name: app-release\n",
+		"nginx.conf":          "listen ${server.port};\nroot ${unknown};\n",
+		"nginx-prod.conf":     "listen 443;\n",
+		"static/data.bin":     "${raw}",
+	})
+
+	return httptest.NewServer(New(NewNativeBackend(dir), WithEncryptor(e))), "file:" + filepath.ToSlash(dir) + "/"
+}
+
+func newTestClient(uri string, profile string, label string) client.Client {
+	return client.NewClient(client.Config{
+		URI:          uri,
+		Applications: []string{"app"},
+		Profiles:     client.SplitList(profile),
+		Label:        label,
+	})
+}
+
+func TestServer_Environment(t *testing.T) {
+	ts, location := testServer(t)
+	defer ts.Close()
+
+	tests := []struct {
+		profile string
+		label   string
+		sources string
+		name    string
+	}{
+		{"default", "", "app.yml|application.yml", "app"},
+		{"test", "", "app.yml|application.yml", "app-test"},
+		{"prod", "master", "app-prod.properties|app.yml|application.yml", "app-prod"},
+		{"default", "release", "release/app.yml|app.yml|application.yml", "app-release"},
+	}
+
+	for _, tt := range tests {
+		env, err := newTestClient(ts.URL, tt.profile, tt.label).FetchEnvironment()
+		if err != nil {
+			t.Fatal("FetchEnvironment failed with: ", err)
+		}
+
+		var sources []string
+		for _, ps := range env.PropertySources {
+			sources = append(sources, strings.TrimPrefix(ps.Name, location))
+		}
+		testutil.AssertString(t, "Incorrect property sources", tt.sources, strings.Join(sources, "|"))
+		testutil.AssertString(t, "Incorrect name", tt.name, fmt.Sprint(env.PropertySources[0].Source["name"]))
+	}
+}
+
+func TestServer_Decrypt(t *testing.T) {
+	ts, _ := testServer(t)
+	defer ts.Close()
+
+	env, err := newTestClient(ts.URL, "prod", "").FetchEnvironment()
+	if err != nil {
+		t.Fatal("FetchEnvironment failed with: ", err)
+	}
+	source := env.PropertySources[0].Source
+	testutil.AssertString(t, "Incorrect decrypted value", "s3cr3t", fmt.Sprint(source["password"]))
+	testutil.AssertString(t, "Incorrect invalid value", "<n/a>", fmt.Sprint(source["invalid.broken"]))
+	testutil.AssertString(t, "Incorrect continued value", "one,two", fmt.Sprint(source["multi"]))
+}
+
+func TestServer_Values(t *testing.T) {
+	ts, _ := testServer(t)
+	defer ts.Close()
+
+	c := newTestClient(ts.URL, "default", "")
+	props, err := c.FetchAsProperties()
+	if err != nil {
+		t.Fatal("FetchAsProperties failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect properties", `log: info
+missing: ${unknown}
+name: app
+server.hosts[0]: a
+server.hosts[1]: b
+server.port: 8080
+url: http://a:8080/api`, props)
+
+	yml, err := c.FetchAsYAML()
+	if err != nil {
+		t.Fatal("FetchAsYAML failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect yaml", `log: info
+missing: ${unknown}
+name: app
+server:
+  hosts:
+  - a
+  - b
+  port: 8080
+url: http://a:8080/api`, yml)
+
+	json, err := c.FetchAsJSON()
+	if err != nil {
+		t.Fatal("FetchAsJSON failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect json",
+		`{"log":"info","missing":"${unknown}","name":"app","server":{"hosts":["a","b"],"port":8080},"url":"http://a:8080/api"}`, json)
+}
+
+func TestServer_Resource(t *testing.T) {
+	ts, _ := testServer(t)
+	defer ts.Close()
+
+	tests := []struct {
+		profile  string
+		file     string
+		binary   bool
+		expected string
+	}{
+		{"default", "nginx.conf", false, "listen 8080;\nroot ${unknown};\n"},
+		{"prod", "nginx.conf", false, "listen 443;\n"},
+		{"default", "static/data.bin", true, "${raw}"},
+	}
+
+	for _, tt := range tests {
+		c := client.NewClient(client.Config{
+			URI:          ts.URL,
+			Applications: []string{"app"},
+			Profiles:     []string{tt.profile},
+			Label:        "master",
+			Binary:       tt.binary,
+		})
+		data, err := c.FetchFileE(tt.file)
+		if err != nil {
+			t.Fatalf("FetchFileE %s failed with: %v", tt.file, err)
+		}
+		testutil.AssertString(t, "Incorrect resource", tt.expected, string(data))
+	}
+
+	_, err := newTestClient(ts.URL, "default", "master").FetchFileE("missing.conf")
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound got %v instead", err)
+	}
+
+	resp, err := http.Get(ts.URL + "/app/default/master/..%2F..%2Fapp.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint: errcheck
+	testutil.AssertString(t, "Incorrect status of path outside of the directory", "404 Not Found", resp.Status)
+
+	c := client.NewClient(client.Config{
+		URI:             ts.URL,
+		Applications:    []string{"app"},
+		Profiles:        []string{"prod"},
+		UseDefaultLabel: true,
+	})
+	if data, err := c.FetchFileE("nginx.conf"); err != nil || string(data) != "listen 443;\n" {
+		t.Errorf("Expected resource from default label got '%s' %v instead", data, err)
+	}
+}
+
+func TestServer_Cipher(t *testing.T) {
+	ts, _ := testServer(t)
+	defer ts.Close()
+
+	c := client.NewClient(client.Config{URI: ts.URL})
+	cipher, err := c.Encrypt("value")
+	if err != nil {
+		t.Fatal("Encrypt failed with: ", err)
+	}
+	plain, err := c.Decrypt(cipher)
+	if err != nil {
+		t.Fatal("Decrypt failed with: ", err)
+	}
+	testutil.AssertString(t, "Incorrect decrypted value", "value", plain)
+
+	if _, err := c.Decrypt("abcd"); !errors.Is(err, client.ErrDecryptionFailed) {
+		t.Errorf("Expected ErrDecryptionFailed got %v instead", err)
+	}
+
+	noKey := httptest.NewServer(New(NewNativeBackend(t.TempDir())))
+	defer noKey.Close()
+	if _, err := client.NewClient(client.Config{URI: noKey.URL}).Encrypt("value"); err == nil {
+		t.Error("Expected encrypt to fail without key")
+	}
+
+	resp, err := http.Post(ts.URL+"/encrypt", "application/x-www-form-urlencoded", strings.NewReader("value="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint: errcheck
+	testutil.AssertString(t, "Incorrect status", "200 OK", resp.Status)
+}
+
+func TestServer_Errors(t *testing.T) {
+	ts, _ := testServer(t)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint: errcheck
+	testutil.AssertString(t, "Incorrect status", "404 Not Found", resp.Status)
+
+	resp, err = http.Post(ts.URL+"/app/default", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint: errcheck
+	testutil.AssertString(t, "Incorrect status", "405 Method Not Allowed", resp.Status)
+
+	missing := httptest.NewServer(New(NewNativeBackend(filepath.Join(t.TempDir(), "missing"))))
+	defer missing.Close()
+	if _, err := newTestClient(missing.URL, "default", "").FetchEnvironment(); err == nil {
+		t.Error("Expected error for missing directory")
+	}
+}
+
+type labelBackend struct{}
+
+func (labelBackend) Snapshot(_ context.Context, label string) (*Snapshot, error) {
+	return nil, fmt.Errorf("%w: %s", ErrNoSuchLabel, label)
+}
+
+func TestServer_NoSuchLabel(t *testing.T) {
+	ts := httptest.NewServer(New(labelBackend{}))
+	defer ts.Close()
+
+	_, err := newTestClient(ts.URL, "default", "feature/foo").FetchAsYAML()
+	if !errors.Is(err, client.ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel got %v instead", err)
+	}
+}
+
+func TestConfigNames(t *testing.T) {
+	names := configNames([]string{"common", "app"}, []string{"dev", "prod"})
+	testutil.AssertString(t, "Incorrect names",
+		"app-prod|common-prod|application-prod|app-dev|common-dev|application-dev|app|common|application", strings.Join(names, "|"))
+}
+
+func TestSearchDirs(t *testing.T) {
+	dirs := searchDirs([]string{"{label}", "", "{application}/{profile}", "../../etc"}, []string{"common", "app"}, []string{"prod"}, "feature/foo")
+	testutil.AssertString(t, "Incorrect dirs", "feature/foo|.|app/prod|common/prod|etc", strings.Join(dirs, "|"))
+}
+
+func TestParseProperties(t *testing.T) {
+	props, err := parseProperties([]byte("# comment\n! comment\na=1\nb : 2\nc 3\nd\\ e=\\u0041\\tb\nf=one\\\n    two\ng=trailing\\\\\nh=\\\n"))
+	if err != nil {
+		t.Fatal("parseProperties failed with: ", err)
+	}
+
+	var res []string
+	for key, value := range props {
+		res = append(res, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(res)
+	testutil.AssertString(t, "Incorrect properties", "a=1|b=2|c=3|d e=A\tb|f=onetwo|g=trailing\\|h=", strings.Join(res, "|"))
+}
+
+func TestTree(t *testing.T) {
+	tr := tree(map[string]interface{}{
+		"a.b[1].c":   1,
+		"a.b[0]":     "x",
+		"a[d.e]":     true,
+		"list[2]":    "z",
+		"[0]":        "root",
+		"plain":      "value",
+		"a.b[1].d.e": "y",
+	})
+	testutil.AssertString(t, "Incorrect tree", "map[[0]:root a:map[b:[x map[c:1 d:map[e:y]]] d.e:true] list:[<nil> <nil> z] plain:value]", fmt.Sprint(tr))
+}
+
+func TestTextEncryptor(t *testing.T) {
+	e, err := NewTextEncryptor("key", DefaultSalt)
+	if err != nil {
+		t.Fatal("NewTextEncryptor failed with: ", err)
+	}
+	other, _ := NewTextEncryptor("other", DefaultSalt)
+
+	for _, value := range []string{"", "value", "exactly 16 bytes", strings.Repeat("long ", 100)} {
+		cipher, err := e.Encrypt(value)
+		if err != nil {
+			t.Fatal("Encrypt failed with: ", err)
+		}
+		plain, err := e.Decrypt(cipher)
+		if err != nil {
+			t.Fatal("Decrypt failed with: ", err)
+		}
+		testutil.AssertString(t, "Incorrect decrypted value", value, plain)
+
+		if plain, err := other.Decrypt(cipher); err == nil && plain == value {
+			t.Error("Expected decryption with other key to fail")
+		}
+	}
+
+	if _, err := NewTextEncryptor("key", "not hex"); err == nil {
+		t.Error("Expected invalid salt to fail")
+	}
+}
@@ -0,0 +1,178 @@
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+const (
+	maxPayloadSize = 1024 * 1024
+
+	// defaultApplication config file names applied to all the applications, e.g. 'application.yml'
+	defaultApplication = "application"
+
+	destinationSeparator = ":"
+)
+
+// refreshEvents Spring Cloud Bus event types triggering the refresh.
+var refreshEvents = map[string]bool{
+	"RefreshRemoteApplicationEvent":           true,
+	"EnvironmentChangeRemoteApplicationEvent": true,
+}
+
+// Notification change of the config announced by the config server.
+type Notification struct {
+	// Destinations Spring Cloud Bus destination patterns, e.g. 'app:**' or 'app:profile:**'
+	Destinations []string
+
+	// Paths changed config files, as sent to the config server '/monitor' endpoint
+	Paths []string
+}
+
+// busEvent Spring Cloud Bus remote application event.
+type busEvent struct {
+	Type               string `json:"type"`
+	DestinationService string `json:"destinationService"`
+}
+
+// pushEvent Git push webhook payload (GitHub, GitLab, Gitea), as accepted by the '/monitor' endpoint.
+type pushEvent struct {
+	Commits []struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+}
+
+// ParseNotification parses the notification from the request. Accepted are Spring Cloud Bus events,
+// 'destination' query parameter as used by the bus refresh endpoint, form encoded 'path' parameters and
+// Git push webhook payloads as accepted by the config server '/monitor' endpoint.
+// Request without any destination or path notifies all the applications.
+// Nil is returned for bus events not requesting the refresh.
+func ParseNotification(r *http.Request) (*Notification, error) {
+	n := &Notification{Destinations: r.URL.Query()["destination"]}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")) // #nosec G104
+	if mediaType == "application/x-www-form-urlencoded" {
+		r.Body = http.MaxBytesReader(nil, r.Body, maxPayloadSize)
+		if err := r.ParseForm(); err != nil {
+			return nil, fmt.Errorf("invalid form payload: %v", err)
+		}
+		n.Paths = append(n.Paths, r.PostForm["path"]...)
+		return n.orAll(), nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxPayloadSize {
+		return nil, fmt.Errorf("payload exceeds %d bytes", maxPayloadSize)
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return n.orAll(), nil
+	}
+
+	var event busEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("invalid JSON payload: %v", err)
+	}
+	if event.Type != "" {
+		if !refreshEvents[event.Type] {
+			return nil, nil
+		}
+		if event.DestinationService != "" {
+			n.Destinations = append(n.Destinations, event.DestinationService)
+		}
+		return n.orAll(), nil
+	}
+
+	var push pushEvent
+	if err := json.Unmarshal(body, &push); err != nil {
+		return nil, fmt.Errorf("invalid JSON payload: %v", err)
+	}
+	for _, c := range push.Commits {
+		n.Paths = append(n.Paths, c.Added...)
+		n.Paths = append(n.Paths, c.Modified...)
+		n.Paths = append(n.Paths, c.Removed...)
+	}
+	return n.orAll(), nil
+}
+
+// orAll notifies all the applications if the notification has no destination nor path.
+func (n *Notification) orAll() *Notification {
+	if len(n.Destinations) == 0 && len(n.Paths) == 0 {
+		n.Destinations = []string{"**"}
+	}
+	return n
+}
+
+// Matches true if the notification concerns any of the applications with any of the profiles.
+func (n *Notification) Matches(applications []string, profiles []string) bool {
+	for _, d := range n.Destinations {
+		if matchDestination(d, applications, profiles) {
+			return true
+		}
+	}
+	for _, p := range n.Paths {
+		if matchPath(p, applications, profiles) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDestination matches the bus destination 'application[:profile][:...]', the parts might be globs.
+func matchDestination(destination string, applications []string, profiles []string) bool {
+	parts := strings.Split(destination, destinationSeparator)
+	if !matchAny(parts[0], applications) {
+		return false
+	}
+	return len(parts) < 2 || matchAny(parts[1], profiles)
+}
+
+// matchPath matches the changed config file, the application and profile are guessed from the file name
+// the way the '/monitor' endpoint does, e.g. 'app-prod.yml' concerns application 'app-prod' and
+// application 'app' with profile 'prod'.
+func matchPath(filename string, applications []string, profiles []string) bool {
+	name := path.Base(filename)
+	name = strings.TrimSuffix(name, path.Ext(name))
+
+	if name == defaultApplication {
+		return true
+	}
+	if slices.Contains(applications, name) {
+		return true
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] != '-' {
+			continue
+		}
+		application, profile := name[:i], name[i+1:]
+		if application == defaultApplication && slices.Contains(profiles, profile) {
+			return true
+		}
+		if slices.Contains(applications, application) && slices.Contains(profiles, profile) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(pattern string, values []string) bool {
+	if pattern == "*" || pattern == "**" {
+		return true
+	}
+	for _, v := range values {
+		if ok, _ := path.Match(pattern, v); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+// Package watch refreshes the config on change notifications of the config server.
+package watch
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+// Watcher calls the refresh function when the config of the watched applications changes.
+// Notifications are received by ServeHTTP, which might be registered as the handler for
+// the webhooks of the config server '/monitor' endpoint or Spring Cloud Bus events.
+// With the non-zero interval the refresh is called periodically as well.
+type Watcher struct {
+	applications []string
+	profiles     []string
+	interval     time.Duration
+	refresh      func(ctx context.Context) error
+	trigger      chan struct{}
+}
+
+// NewWatcher creates the Watcher of the applications and profiles of the client config.
+func NewWatcher(c client.Config, interval time.Duration, refresh func(ctx context.Context) error) *Watcher {
+	return &Watcher{
+		applications: c.Applications,
+		profiles:     c.Profiles,
+		interval:     interval,
+		refresh:      refresh,
+		trigger:      make(chan struct{}, 1),
+	}
+}
+
+// Trigger requests the immediate refresh, requests received while the refresh is pending are coalesced.
+func (w *Watcher) Trigger() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Notify triggers the refresh if the notification concerns the watched applications, returns true if triggered.
+func (w *Watcher) Notify(n *Notification) bool {
+	if n == nil || !n.Matches(w.applications, w.profiles) {
+		return false
+	}
+	w.Trigger()
+	return true
+}
+
+// ServeHTTP accepts the change notification, responds 202 if the refresh was triggered
+// and 204 if the notification does not concern the watched applications.
+func (w *Watcher) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	n, err := ParseNotification(r)
+	if err != nil {
+		log.Warnf("Invalid change notification: %v", err)
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !w.Notify(n) {
+		log.Debugf("Ignoring change notification %+v", n)
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	log.Infof("Change notification %+v received, refreshing", n)
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+// Run calls the refresh on every trigger or interval until the context is done.
+// Refresh errors are logged and the watcher carries on.
+func (w *Watcher) Run(ctx context.Context) error {
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick:
+		case <-w.trigger:
+		}
+
+		if err := w.refresh(ctx); err != nil {
+			log.Errorf("Config refresh failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package watch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wandera/scccmd/pkg/client"
+)
+
+func TestParseNotification(t *testing.T) {
+	testParams := []struct {
+		name         string
+		target       string
+		contentType  string
+		body         string
+		destinations string
+		paths        string
+		ignored      bool
+		wantErr      bool
+	}{
+		{"empty", "/", "", "", "**", "", false, false},
+		{"destination", "/actuator/busrefresh?destination=app:**", "", "", "app:**", "", false, false},
+		{"bus refresh", "/", "application/json", `{"type":"RefreshRemoteApplicationEvent","destinationService":"app:prod:**"}`, "app:prod:**", "", false, false},
+		{"bus env change", "/", "application/json", `{"type":"EnvironmentChangeRemoteApplicationEvent"}`, "**", "", false, false},
+		{"bus ack", "/", "application/json", `{"type":"AckRemoteApplicationEvent","destinationService":"**"}`, "", "", true, false},
+		{"form", "/monitor", "application/x-www-form-urlencoded", url.Values{"path": {"app.yml", "other.yml"}}.Encode(), "", "app.yml|other.yml", false, false},
+		{"push", "/monitor", "application/json", `{"commits":[{"added":["a.yml"],"modified":["b.yml"],"removed":["c.yml"]}]}`, "", "a.yml|b.yml|c.yml", false, false},
+		{"invalid", "/", "application/json", `{`, "", "", false, true},
+	}
+
+	for _, tp := range testParams {
+		r := httptest.NewRequest(http.MethodPost, tp.target, strings.NewReader(tp.body))
+		if tp.contentType != "" {
+			r.Header.Set("Content-Type", tp.contentType)
+		}
+
+		n, err := ParseNotification(r)
+		if tp.wantErr != (err != nil) {
+			t.Errorf("%s: unexpected error %v", tp.name, err)
+			continue
+		}
+		if tp.wantErr {
+			continue
+		}
+		if tp.ignored != (n == nil) {
+			t.Errorf("%s: expected ignored %v got %+v instead", tp.name, tp.ignored, n)
+			continue
+		}
+		if n == nil {
+			continue
+		}
+		if got := strings.Join(n.Destinations, "|"); got != tp.destinations {
+			t.Errorf("%s: expected destinations '%s' got '%s' instead", tp.name, tp.destinations, got)
+		}
+		if got := strings.Join(n.Paths, "|"); got != tp.paths {
+			t.Errorf("%s: expected paths '%s' got '%s' instead", tp.name, tp.paths, got)
+		}
+	}
+}
+
+func TestNotification_Matches(t *testing.T) {
+	applications := []string{"app"}
+	profiles := []string{"default", "prod"}
+
+	testParams := []struct {
+		notification Notification
+		expected     bool
+	}{
+		{Notification{Destinations: []string{"**"}}, true},
+		{Notification{Destinations: []string{"app:**"}}, true},
+		{Notification{Destinations: []string{"app"}}, true},
+		{Notification{Destinations: []string{"ap*:prod"}}, true},
+		{Notification{Destinations: []string{"app:dev:**"}}, false},
+		{Notification{Destinations: []string{"other:**"}}, false},
+		{Notification{Paths: []string{"application.yml"}}, true},
+		{Notification{Paths: []string{"config/app.properties"}}, true},
+		{Notification{Paths: []string{"app-prod.yml"}}, true},
+		{Notification{Paths: []string{"application-prod.yml"}}, true},
+		{Notification{Paths: []string{"app-dev.yml"}}, false},
+		{Notification{Paths: []string{"application-dev.yml"}}, false},
+		{Notification{Paths: []string{"other.yml", "app-default.yml"}}, true},
+		{Notification{Paths: []string{"other-app.yml"}}, false},
+	}
+
+	for _, tp := range testParams {
+		if got := tp.notification.Matches(applications, profiles); got != tp.expected {
+			t.Errorf("Expected %v got %v instead for %+v", tp.expected, got, tp.notification)
+		}
+	}
+}
+
+func TestWatcher_ServeHTTP(t *testing.T) {
+	w := NewWatcher(client.Config{Applications: []string{"app"}, Profiles: []string{"default"}}, 0, nil)
+
+	testParams := []struct {
+		method    string
+		target    string
+		status    int
+		triggered bool
+	}{
+		{http.MethodGet, "/", http.StatusMethodNotAllowed, false},
+		{http.MethodPost, "/?destination=other:**", http.StatusNoContent, false},
+		{http.MethodPost, "/?destination=app:**", http.StatusAccepted, true},
+	}
+
+	for _, tp := range testParams {
+		rec := httptest.NewRecorder()
+		w.ServeHTTP(rec, httptest.NewRequest(tp.method, tp.target, nil))
+		if rec.Code != tp.status {
+			t.Errorf("Expected status %d got %d instead for %s %s", tp.status, rec.Code, tp.method, tp.target)
+		}
+
+		select {
+		case <-w.trigger:
+			if !tp.triggered {
+				t.Errorf("Unexpected refresh triggered by %s %s", tp.method, tp.target)
+			}
+		default:
+			if tp.triggered {
+				t.Errorf("Expected refresh triggered by %s %s", tp.method, tp.target)
+			}
+		}
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	var count int32
+	refreshed := make(chan struct{}, 10)
+	w := NewWatcher(client.Config{Applications: []string{"app"}, Profiles: []string{"default"}}, 0, func(ctx context.Context) error {
+		atomic.AddInt32(&count, 1)
+		refreshed <- struct{}{}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	w.Trigger()
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected refresh to be called")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run failed with: %v", err)
+	}
+	if c := atomic.LoadInt32(&count); c != 1 {
+		t.Errorf("Expected 1 refresh got %d instead", c)
+	}
+}